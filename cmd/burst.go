@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/kubeconfigpkg"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+	"github.com/sarthakK31/podcraft/pkg/spec"
+	"github.com/sarthakK31/podcraft/pkg/validate"
+)
+
+var burstFor time.Duration
+var burstCancel bool
+
+var burstCmd = &cobra.Command{
+	Use:   "burst [username]",
+	Short: "Temporarily raise the quota of a developer environment",
+	Long: `Temporarily raise the quota of a developer environment.
+
+The given limits replace the environment's own until the burst expires;
+the others are kept. The burst and its expiry are recorded on the
+namespace. Once expired, the next create, update, repair or reconcile of
+the environment restores its quota, so run "podcraft reconcile --all"
+periodically to end expired bursts. A new burst replaces an active one,
+and --cancel ends it right away.`,
+	Example: `  podcraft burst aman --memory 8Gi --for 24h
+  podcraft burst aman --env feature-x --cpu 4 --max-pods 20 --for 2h
+  podcraft burst aman --cancel`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		username := args[0]
+
+		err := validateEnvironment(username, envName)
+		if err != nil {
+			return err
+		}
+
+		burst := &spec.Burst{}
+		given := false
+
+		if cmd.Flags().Changed("cpu") {
+			err = validate.Quantity("cpu", cpuLimit)
+			if err != nil {
+				return err
+			}
+			burst.CPU = cpuLimit
+			given = true
+		}
+		if cmd.Flags().Changed("memory") {
+			err = validate.Quantity("memory", memoryLimit)
+			if err != nil {
+				return err
+			}
+			burst.Memory = memoryLimit
+			given = true
+		}
+		if cmd.Flags().Changed("storage") {
+			err = validate.Quantity("storage", storageLimit)
+			if err != nil {
+				return err
+			}
+			burst.Storage = storageLimit
+			given = true
+		}
+		if cmd.Flags().Changed("max-pods") {
+			err = validate.Count("max-pods", maxPods)
+			if err != nil {
+				return err
+			}
+			burst.MaxPods = maxPods
+			given = true
+		}
+
+		if burstCancel && given {
+			return fmt.Errorf("--cancel cannot be combined with limits")
+		}
+		if !burstCancel && !given {
+			return fmt.Errorf("give --cpu, --memory, --storage or --max-pods to raise, or --cancel")
+		}
+		if burstFor <= 0 {
+			return fmt.Errorf("--for must be a positive duration such as 24h")
+		}
+
+		namespace := namespacepkg.Name(username, envName)
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		env, ok, err := recordedEnvironment(clientset, namespace)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("namespace %s does not exist; use \"podcraft create\"", namespace)
+		}
+
+		now := time.Now()
+		before := env.Effective(now)
+
+		if burstCancel {
+			if !env.Burst.Active(now) {
+				fmt.Println("No active quota burst:", namespace)
+				return nil
+			}
+			env.Burst = nil
+		} else {
+			burst.ExpiresAt = now.Add(burstFor).UTC().Format(time.RFC3339)
+			env.Burst = burst
+
+			err = validateBurst(env)
+			if err != nil {
+				return err
+			}
+		}
+
+		after := env.Effective(now)
+
+		hard, err := quota.Hard(after.CPU, after.Memory, after.Storage, after.MaxPods)
+		if err != nil {
+			return err
+		}
+
+		if burstCancel {
+			err = quota.CheckUsage(clientset, namespace, hard)
+			if err != nil {
+				return fmt.Errorf("%w; the burst expires at %s", err, before.Burst.ExpiresAt)
+			}
+		}
+
+		err = admitEnvironment(clientset, after, hard, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		pipeline := newPipeline(clientset, env, kubeconfigpkg.Options{}, pruneStale, nil)
+		pipeline.Skip("credentials")
+
+		err = runPipeline(clientset, pipeline, env, "", onFailureDegraded, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "LIMIT\tBEFORE\tAFTER")
+		for _, row := range [][3]string{
+			{"cpu", before.CPU, after.CPU},
+			{"memory", before.Memory, after.Memory},
+			{"storage", before.Storage, after.Storage},
+			{"max-pods", strconv.Itoa(before.MaxPods), strconv.Itoa(after.MaxPods)},
+		} {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", row[0], row[1], row[2])
+		}
+		w.Flush()
+
+		if burstCancel {
+			fmt.Println("\nQuota burst ended:", namespace)
+		} else {
+			fmt.Printf("\nQuota of %s raised until %s\n", namespace, burst.ExpiresAt)
+		}
+
+		return nil
+	},
+}
+
+// validateBurst checks that every limit the burst of env gives is above the
+// environment's own.
+func validateBurst(env spec.Environment) error {
+
+	baseline, err := quota.Hard(env.CPU, env.Memory, env.Storage, env.MaxPods)
+	if err != nil {
+		return err
+	}
+
+	pods := ""
+	if env.Burst.MaxPods != 0 {
+		pods = strconv.Itoa(env.Burst.MaxPods)
+	}
+
+	raises := []struct {
+		flag  string
+		name  corev1.ResourceName
+		value string
+	}{
+		{"cpu", corev1.ResourceLimitsCPU, env.Burst.CPU},
+		{"memory", corev1.ResourceLimitsMemory, env.Burst.Memory},
+		{"storage", corev1.ResourceRequestsStorage, env.Burst.Storage},
+		{"max-pods", corev1.ResourcePods, pods},
+	}
+
+	for _, r := range raises {
+		if r.value == "" {
+			continue
+		}
+		value, err := resource.ParseQuantity(r.value)
+		if err != nil {
+			return fmt.Errorf("invalid --%s %q: %w", r.flag, r.value, err)
+		}
+		limit := baseline[r.name]
+		if value.Cmp(limit) <= 0 {
+			return fmt.Errorf("--%s %s does not raise the quota (%s); a burst can only raise it, use \"podcraft update\" to lower it", r.flag, r.value, limit.String())
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(burstCmd)
+	burstCmd.Flags().StringVar(&envName, "env", "", "Named environment to raise the quota of")
+	burstCmd.Flags().StringVar(&cpuLimit, "cpu", "2", "Total CPU limit for namespace during the burst")
+	burstCmd.Flags().StringVar(&memoryLimit, "memory", "2Gi", "Total memory limit for namespace during the burst")
+	burstCmd.Flags().StringVar(&storageLimit, "storage", quota.DefaultStorage, "Total storage requests of PersistentVolumeClaims during the burst")
+	burstCmd.Flags().IntVar(&maxPods, "max-pods", 10, "Maximum number of pods during the burst")
+	burstCmd.Flags().DurationVar(&burstFor, "for", 24*time.Hour, "How long the burst lasts")
+	burstCmd.Flags().BoolVar(&burstCancel, "cancel", false, "End an active burst and restore the quota")
+	addCapacityFlags(burstCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sarthakK31/podcraft/pkg/spec"
+)
+
+func TestValidateBurst(t *testing.T) {
+
+	baseline := spec.Environment{Owner: "aman", CPU: "2", Memory: "2Gi", Storage: "5Gi", MaxPods: 10}
+
+	tests := []struct {
+		name    string
+		burst   spec.Burst
+		wantErr string
+	}{
+		{"raises memory", spec.Burst{Memory: "8Gi"}, ""},
+		{"raises everything", spec.Burst{CPU: "4", Memory: "4Gi", Storage: "20Gi", MaxPods: 20}, ""},
+		{"lower cpu", spec.Burst{CPU: "1"}, "--cpu 1 does not raise the quota"},
+		{"equal pods", spec.Burst{MaxPods: 10}, "--max-pods 10 does not raise the quota"},
+		{"lower storage", spec.Burst{Storage: "1Gi"}, "--storage 1Gi does not raise the quota"},
+		{"unparsable memory", spec.Burst{Memory: "8GB"}, `invalid --memory "8GB"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := baseline
+			env.Burst = &tt.burst
+
+			err := validateBurst(env)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateBurst() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateBurst() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sarthakK31/podcraft/pkg/cluster"
+	"github.com/sarthakK31/podcraft/pkg/validate"
+)
+
+var clusterName string
+var clustersFile string
+var allClusters bool
+var clusterDefaults map[string]string
+
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Manage the registry of clusters",
+	Long: `Manage the registry of clusters PodCraft provisions environments on.
+
+Each registered cluster has a kubeconfig, an optional context and optional
+defaults for command flags. --cluster selects a registered cluster for any
+command; create, list, describe and delete also take --all-clusters to run
+on every registered cluster. The registry is stored in
+~/.podcraft/clusters.yaml unless --clusters-file is given.`,
+}
+
+var clusterAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Register a cluster or update its registration",
+	Example: `  podcraft cluster add staging --kubeconfig ~/.kube/staging --context kind-staging
+  podcraft cluster add dev --context kind-dev --default cpu=1 --default max-pods=5`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		name := args[0]
+
+		err := validate.Name("cluster", name)
+		if err != nil {
+			return err
+		}
+
+		registry, err := cluster.Load(clustersFile)
+		if err != nil {
+			return err
+		}
+
+		registry.Clusters[name] = cluster.Cluster{
+			Kubeconfig: kubeconfig,
+			Context:    kubeContext,
+			Defaults:   clusterDefaults,
+		}
+
+		err = registry.Save(clustersFile)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Cluster registered:", name)
+
+		return nil
+	},
+}
+
+var clusterRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Unregister a cluster",
+	Long:  `Unregister a cluster. Environments on the cluster are left alone.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		registry, err := cluster.Load(clustersFile)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := registry.Clusters[args[0]]; !ok {
+			fmt.Println("Cluster is not registered:", args[0])
+			return nil
+		}
+
+		delete(registry.Clusters, args[0])
+
+		err = registry.Save(clustersFile)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Cluster unregistered:", args[0])
+
+		return nil
+	},
+}
+
+var clusterListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered clusters",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		registry, err := cluster.Load(clustersFile)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "NAME\tKUBECONFIG\tCONTEXT\tDEFAULTS")
+
+		for _, name := range registry.Names() {
+			c := registry.Clusters[name]
+
+			defaults := []string{}
+			for key, value := range c.Defaults {
+				defaults = append(defaults, key+"="+value)
+			}
+			slices.Sort(defaults)
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, c.Kubeconfig, c.Context, strings.Join(defaults, ","))
+		}
+
+		w.Flush()
+
+		return nil
+	},
+}
+
+// selectCluster points the kubeconfig and context at a registered cluster
+// and applies its flag defaults to cmd. Flags given on the command line
+// win; defaults another cluster set are reset first.
+func selectCluster(cmd *cobra.Command, registry *cluster.Registry, name string) error {
+
+	c, err := registry.Get(name)
+	if err != nil {
+		return err
+	}
+
+	clusterName = name
+
+	if !cmd.Flags().Changed("kubeconfig") {
+		kubeconfig = c.Kubeconfig
+	}
+	if !cmd.Flags().Changed("context") {
+		kubeContext = c.Context
+	}
+
+	for _, other := range registry.Clusters {
+		for key := range other.Defaults {
+			f := cmd.Flags().Lookup(key)
+			if f == nil || f.Changed {
+				continue
+			}
+
+			value, ok := c.Defaults[key]
+			if !ok {
+				value = f.DefValue
+			}
+
+			err = f.Value.Set(value)
+			if err != nil {
+				return fmt.Errorf("cluster %s: default %s: %w", name, key, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// forEachCluster runs fn on every registered cluster with --all-clusters,
+// and once on the selected cluster otherwise. A failure on one cluster does
+// not stop the others.
+func forEachCluster(cmd *cobra.Command, fn func(name string) error) error {
+
+	if !allClusters {
+		return fn(clusterName)
+	}
+
+	registry, err := cluster.Load(clustersFile)
+	if err != nil {
+		return err
+	}
+
+	names := registry.Names()
+	if len(names) == 0 {
+		return fmt.Errorf("no clusters registered; use \"podcraft cluster add\"")
+	}
+
+	failed := []string{}
+
+	for _, name := range names {
+		err := selectCluster(cmd, registry, name)
+		if err == nil {
+			err = fn(name)
+		}
+		if err != nil {
+			fmt.Printf("Error on cluster %s: %v\n", name, err)
+			failed = append(failed, name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed on clusters: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// onEachCluster makes a command run on every registered cluster with
+// --all-clusters, announcing each cluster.
+func onEachCluster(run func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		return forEachCluster(cmd, func(name string) error {
+			if allClusters {
+				fmt.Printf("\n=== Cluster %s ===\n", name)
+			}
+			return run(cmd, args)
+		})
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(clusterCmd)
+	clusterCmd.AddCommand(clusterAddCmd)
+	clusterCmd.AddCommand(clusterRemoveCmd)
+	clusterCmd.AddCommand(clusterListCmd)
+
+	clusterAddCmd.Flags().StringToStringVar(&clusterDefaults, "default", nil, "Default for a command flag on this cluster, as flag=value (repeatable)")
+}
@@ -1,83 +1,352 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 
+	"github.com/sarthakK31/podcraft/pkg/budget"
+	"github.com/sarthakK31/podcraft/pkg/capacity"
 	"github.com/sarthakK31/podcraft/pkg/kube"
 	"github.com/sarthakK31/podcraft/pkg/kubeconfigpkg"
 	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
-	"github.com/sarthakK31/podcraft/pkg/network"
 	"github.com/sarthakK31/podcraft/pkg/quota"
-	"github.com/sarthakK31/podcraft/pkg/rbac"
+	"github.com/sarthakK31/podcraft/pkg/spec"
+	"github.com/sarthakK31/podcraft/pkg/validate"
 )
 
 var cpuLimit string
 var memoryLimit string
 var maxPods int
+var kubeconfigDir string
+var force bool
+var allowOvercommit bool
+var encryptTo string
+var envName string
+var maxEnvs int
+var teamName string
+var nodeSelector string
+var maxOvercommit float64
+var onFailure string
+var pruneStale bool
+var storageLimit string
+var podSecurity string
 
 var createCmd = &cobra.Command{
 	Use:   "create [username]",
 	Short: "Create developer environment",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Create a developer environment.
 
-		username := args[0]
-		namespace := "dev-" + username
+With --from-file, every developer of a roster is provisioned concurrently
+instead. The roster is a CSV file with the columns user, team, profile and
+ttl (only user is required), or a YAML list of entries with the same keys.
+The profile (small, medium or large) sets the quota instead of --cpu,
+--memory and --max-pods, and ttl (e.g. 72h or 30d) records when the
+environment expires. A summary of every developer is printed at the end,
+and create fails if any environment failed.`,
+	Example: `  podcraft create aman
+  podcraft create aman --env feature-x
+  podcraft create --from-file interns.csv --kubeconfig-dir ./kubeconfigs --workers 8`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 
-		clientset, err := kube.GetClient(kubeconfig)
-		if err != nil {
-			panic(err)
+		if onFailure != onFailureRollback && onFailure != onFailureDegraded {
+			return fmt.Errorf("--on-failure must be %q or %q", onFailureRollback, onFailureDegraded)
 		}
 
-		// ctx := context.Background()
+		if rosterFile != "" && len(args) > 0 {
+			return fmt.Errorf("give either a username or --from-file, not both")
+		}
+		if rosterFile == "" && len(args) == 0 {
+			return fmt.Errorf("a username or --from-file is required")
+		}
 
-		// Creating Namespace (Idempotent)
-		err = namespacepkg.EnsureNamespace(clientset, namespace, username)
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
 		if err != nil {
-			panic(err)
+			return err
 		}
 
-		// Creating RBAC (Idempotent) - service-account, role, rolebinding
-		err = rbac.EnsureRBAC(clientset, namespace, username)
-		if err != nil {
-			panic(err)
+		if rosterFile != "" {
+			return createFromRoster(cmd, clientset)
 		}
 
-		// Generating kubeconfig for the user and loading Service account token
-		err = kubeconfigpkg.Generate(clientset, kubeconfig, namespace, username)
-		if err != nil {
-			panic(err)
+		username := args[0]
+
+		env := spec.Environment{
+			Owner:       username,
+			Env:         envName,
+			Team:        teamName,
+			CPU:         cpuLimit,
+			Memory:      memoryLimit,
+			MaxPods:     maxPods,
+			Storage:     storageLimit,
+			PodSecurity: podSecurity,
 		}
 
-		// Applying Network Policies
+		err = validateEnvironment(env.Owner, env.Env)
+		if err != nil {
+			return err
+		}
 
-		err = network.EnsureNetwork(clientset, namespace)
+		// Re-running create keeps the settings of an existing environment
+		// unless they are given again
+		recorded, ok, err := recordedEnvironment(clientset, env.Namespace())
 		if err != nil {
-			panic(err)
+			return err
 		}
-		fmt.Println("NetworkPolicies applied")
+		if ok {
+			env = keepRecorded(cmd, env, recorded)
 
-		// Applying ResourceQuota (Hardcoded Defaults)
+			fmt.Printf("Keeping the recorded settings of %s not given as flags: cpu=%s memory=%s storage=%s max-pods=%d\n",
+				env.Namespace(), env.CPU, env.Memory, env.Storage, env.MaxPods)
+		}
 
-		err = quota.EnsureQuota(clientset, namespace, cpuLimit, memoryLimit, maxPods)
+		err = provisionEnvironment(clientset, env, pruneStale, onFailure, nil)
 		if err != nil {
-			panic(err)
+			return err
 		}
 
-		fmt.Println("Developer environment ready:", namespace)
+		fmt.Println("Developer environment ready:", env.Namespace())
 		fmt.Println("\nStorage Policy:")
 		fmt.Println("- Pods use ephemeral storage by default.")
 		fmt.Println("- To persist data, create a PersistentVolumeClaim (PVC).")
-		fmt.Println("- Maximum storage allowed in this namespace: 5Gi.")
+		fmt.Printf("- Maximum storage allowed in this namespace: %s.\n", env.Storage)
 		fmt.Println("- Deleting the namespace deletes all PVCs and data.")
+
+		return nil
 	},
 }
 
+// keepRecorded returns env with the recorded settings of the existing
+// environment, except for those given as flags on the command line.
+func keepRecorded(cmd *cobra.Command, env spec.Environment, recorded spec.Environment) spec.Environment {
+
+	if !cmd.Flags().Changed("team") {
+		env.Team = recorded.Team
+	}
+	env.CPU = recorded.CPU
+	env.Memory = recorded.Memory
+	env.MaxPods = recorded.MaxPods
+	env.Storage = recorded.Storage
+	env.PodSecurity = recorded.PodSecurity
+	env.ExpiresAt = recorded.ExpiresAt
+	env.Burst = recorded.Burst
+	applySettingFlags(cmd, &env)
+
+	return env
+}
+
+// provisionEnvironment validates env, admits it and runs its provisioning
+// pipeline, handling a failed phase according to onFailure.
+func provisionEnvironment(clientset *kubernetes.Clientset, env spec.Environment, pruneStale bool, onFailure string, r *kube.Reporter) error {
+
+	env, hard, err := prepareEnvironment(env)
+	if err != nil {
+		return err
+	}
+
+	err = admitEnvironment(clientset, env, hard, nil, r)
+	if err != nil {
+		return err
+	}
+
+	return provisionAdmitted(clientset, env, pruneStale, onFailure, r)
+}
+
+// prepareEnvironment normalizes and validates env and returns it with the
+// hard limits it is admitted with.
+func prepareEnvironment(env spec.Environment) (spec.Environment, corev1.ResourceList, error) {
+
+	if env.Env == namespacepkg.DefaultEnv {
+		env.Env = ""
+	}
+
+	// Validate all input before touching the cluster
+	err := validateEnvironment(env.Owner, env.Env)
+	if err != nil {
+		return spec.Environment{}, nil, err
+	}
+
+	err = validateQuota(env.CPU, env.Memory, env.MaxPods)
+	if err != nil {
+		return spec.Environment{}, nil, err
+	}
+
+	err = validateSettings(env.Storage, env.PodSecurity)
+	if err != nil {
+		return spec.Environment{}, nil, err
+	}
+
+	if env.Team != "" {
+		err = validate.Name("team", env.Team)
+		if err != nil {
+			return spec.Environment{}, nil, err
+		}
+	}
+
+	// An active quota burst is what the environment is admitted with
+	limits := env.Effective(time.Now())
+
+	hard, err := quota.Hard(limits.CPU, limits.Memory, limits.Storage, limits.MaxPods)
+	if err != nil {
+		return spec.Environment{}, nil, err
+	}
+
+	return env, hard, nil
+}
+
+// provisionAdmitted runs the provisioning pipeline of an environment that was
+// admitted, handling a failed phase according to onFailure.
+func provisionAdmitted(clientset *kubernetes.Clientset, env spec.Environment, pruneStale bool, onFailure string, r *kube.Reporter) error {
+
+	pipeline := newPipeline(clientset, env, kubeconfigpkg.Options{
+		Dir:       kubeconfigDir,
+		Force:     force,
+		EncryptTo: encryptTo,
+		Cluster:   clusterName,
+	}, pruneStale, r)
+
+	return runPipeline(clientset, pipeline, env, "", onFailure, r)
+}
+
+// admission is an environment admitted earlier in the same run, which
+// budgets and capacity must count before it is provisioned.
+type admission struct {
+	team string
+	hard corev1.ResourceList
+}
+
+// admitEnvironment checks a new or existing environment against the
+// per-developer cap, its team budget and cluster capacity before anything is
+// provisioned. The hard limits of the environments in admitted, keyed by
+// namespace, count as allocated.
+func admitEnvironment(clientset *kubernetes.Clientset, env spec.Environment, hard corev1.ResourceList, admitted map[string]admission, r *kube.Reporter) error {
+
+	namespace := env.Namespace()
+	homeNamespace := env.HomeNamespace()
+
+	ctx := context.Background()
+
+	// Named environments share the identity of the default environment
+	if namespace != homeNamespace {
+		home, err := clientset.CoreV1().
+			Namespaces().
+			Get(ctx, homeNamespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("default environment %s does not exist; run \"podcraft create %s\" first", homeNamespace, env.Owner)
+		}
+		if err != nil {
+			return err
+		}
+		if owner := namespacepkg.Owner(home); owner != env.Owner {
+			return fmt.Errorf("namespace %s belongs to %s, not the default environment of %s", homeNamespace, owner, env.Owner)
+		}
+	}
+
+	// Enforce the per-developer environment cap on new environments
+	existing, err := clientset.CoreV1().
+		Namespaces().
+		Get(ctx, namespace, metav1.GetOptions{})
+	if err == nil {
+		// dev-aman-feature-x is both aman/feature-x and aman-feature/x;
+		// whichever was created first keeps the name
+		if owner := namespacepkg.Owner(existing); owner != env.Owner {
+			return fmt.Errorf("namespace %s already belongs to %s", namespace, owner)
+		}
+	} else if apierrors.IsNotFound(err) {
+		count, err := namespacepkg.CountEnvs(clientset, env.Owner)
+		if err != nil {
+			return err
+		}
+		if count >= maxEnvs {
+			return fmt.Errorf("%s already has %d environments (limit %d)", env.Owner, count, maxEnvs)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	// Admit the environment against its team budget
+	if env.Team != "" {
+		admit := map[string]corev1.ResourceList{namespace: hard}
+		for ns, a := range admitted {
+			if a.team == env.Team && ns != namespace {
+				admit[ns] = a.hard
+			}
+		}
+		err = budget.Check(clientset, env.Team, admit, r)
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("team %s does not exist; run \"podcraft team create %s\" first", env.Team, env.Team)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Refuse quotas the cluster cannot back, unless allowed
+	admit := map[string]corev1.ResourceList{namespace: hard}
+	for ns, a := range admitted {
+		if ns != namespace {
+			admit[ns] = a.hard
+		}
+	}
+	usage, err := capacity.Overcommit(clientset, admit, nodeSelector)
+	if err != nil {
+		return err
+	}
+	err = capacity.Check(usage, maxOvercommit, r)
+	if err != nil && !allowOvercommit {
+		return fmt.Errorf("%w (use --allow-overcommit to provision anyway)", err)
+	}
+	if err != nil {
+		r.Println("WARNING:", err)
+	}
+
+	return nil
+}
+
+// addCapacityFlags adds the flags of the cluster capacity check to cmd.
+func addCapacityFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&nodeSelector, "node-selector", "", "Only count capacity of nodes matching this label selector")
+	cmd.Flags().Float64Var(&maxOvercommit, "max-overcommit", 2.0, "Maximum ratio of allocated quota to node allocatable capacity")
+	cmd.Flags().BoolVar(&allowOvercommit, "allow-overcommit", false, "Proceed beyond --max-overcommit with a warning")
+}
+
+// addAdmissionFlags adds the flags of the checks admitEnvironment runs on a
+// new environment to cmd.
+func addAdmissionFlags(cmd *cobra.Command) {
+	addCapacityFlags(cmd)
+	cmd.Flags().IntVar(&maxEnvs, "max-envs", 3, "Maximum number of environments per developer")
+}
+
+// addKubeconfigFlags adds the flags controlling where and how the developer
+// kubeconfig is written to cmd.
+func addKubeconfigFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&kubeconfigDir, "kubeconfig-dir", ".", "Directory to write the developer kubeconfig to")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing developer kubeconfig")
+	cmd.Flags().StringVar(&encryptTo, "encrypt-to", "", "Encrypt the kubeconfig to an age or SSH public key (or a file of recipients)")
+}
+
 func init() {
 	rootCmd.AddCommand(createCmd)
+	createCmd.RunE = onEachCluster(createCmd.RunE)
+	createCmd.Flags().BoolVar(&allClusters, "all-clusters", false, "Run on every registered cluster")
 	createCmd.Flags().StringVar(&cpuLimit, "cpu", "2", "Total CPU limit for namespace")
 	createCmd.Flags().StringVar(&memoryLimit, "memory", "2Gi", "Total memory limit for namespace")
 	createCmd.Flags().IntVar(&maxPods, "max-pods", 10, "Maximum number of pods")
+	createCmd.Flags().StringVar(&storageLimit, "storage", quota.DefaultStorage, "Total storage requests of PersistentVolumeClaims in the namespace")
+	createCmd.Flags().StringVar(&podSecurity, "pod-security", "", "Pod Security Standard to enforce: privileged, baseline or restricted, or none to remove it")
+	createCmd.Flags().StringVar(&envName, "env", "", "Named environment to create (namespace dev-<username>-<env>)")
+	createCmd.Flags().StringVar(&teamName, "team", "", "Team whose budget the environment counts against")
+	createCmd.Flags().StringVar(&onFailure, "on-failure", onFailureRollback, "When a phase fails: rollback what was created, or leave the environment degraded for repair")
+	createCmd.Flags().BoolVar(&pruneStale, "prune", false, "Delete PodCraft-managed objects no longer in the desired set")
+	createCmd.Flags().StringVar(&rosterFile, "from-file", "", "Provision every developer of a CSV or YAML roster")
+	createCmd.Flags().IntVar(&workers, "workers", 4, "Number of environments --from-file provisions concurrently")
+	addAdmissionFlags(createCmd)
+	addKubeconfigFlags(createCmd)
 }
@@ -1,52 +1,194 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/sarthakK31/podcraft/pkg/bundle"
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/kubeconfigpkg"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
 )
 
+var assumeYes bool
+var waitDeleted bool
+var waitTimeout time.Duration
+var archive bool
+
 var deleteCmd = &cobra.Command{
 	Use:   "delete [username]",
 	Short: "Delete developer environment",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Delete a developer environment and everything in it.
+
+The namespace name must be typed to confirm unless --yes is given. Namespaces
+not managed by PodCraft and namespaces labelled podcraft.dev/protected=true
+are never deleted. The generated kubeconfig of the environment is removed
+from --kubeconfig-dir. With --archive, the environment is exported to a
+bundle first (see "podcraft export") and nothing is deleted if that fails.`,
+	Example: `  podcraft delete aman --env feature-x
+  podcraft delete aman --yes --wait`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
 
 		username := args[0]
-		namespace := "dev-" + username
 
-		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+		err := validateEnvironment(username, envName)
 		if err != nil {
-			panic(err)
+			return err
 		}
 
-		clientset, err := kubernetes.NewForConfig(config)
+		namespace := namespacepkg.Name(username, envName)
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
 		if err != nil {
-			panic(err)
+			return err
 		}
 
-		err = clientset.CoreV1().
+		ns, err := clientset.CoreV1().
 			Namespaces().
-			Delete(context.Background(), namespace, metav1.DeleteOptions{})
+			Get(context.Background(), namespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			fmt.Println("Namespace does not exist:", namespace)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = checkDeletable(clientset, ns, username)
+		if err != nil {
+			return err
+		}
 
+		if !assumeYes {
+			err = confirmDeletion(cmd, namespace)
+			if err != nil {
+				return err
+			}
+		}
+
+		if archive && ns.DeletionTimestamp == nil {
+			fileName := bundleFile
+			if fileName == "" {
+				fileName = bundle.FileName(namespace)
+			}
+			err = exportBundle(clientset, namespace, fileName)
+			if err != nil {
+				return fmt.Errorf("archiving %s: %w; nothing deleted", namespace, err)
+			}
+		}
+
+		err = removeEnvironment(clientset, ns)
 		if err != nil {
-			if apierrors.IsNotFound(err) {
-				fmt.Println("Namespace does not exist:", namespace)
-				return
+			return err
+		}
+
+		if waitDeleted {
+			err = namespacepkg.WaitDeleted(clientset, namespace, waitTimeout)
+			if err != nil {
+				return err
 			}
-			panic(err)
+			fmt.Println("Namespace terminated:", namespace)
 		}
 
-		fmt.Println("Deleted namespace:", namespace)
+		return nil
 	},
 }
 
+// checkDeletable refuses namespaces PodCraft must not delete: unmanaged or
+// protected ones, those of another developer, and default environments
+// whose identity named environments still use.
+func checkDeletable(clientset *kubernetes.Clientset, ns *corev1.Namespace, username string) error {
+
+	if ns.Labels[namespacepkg.ManagedLabel] != "true" {
+		return fmt.Errorf("namespace %s is not managed by PodCraft; refusing to delete it", ns.Name)
+	}
+	if ns.Labels[namespacepkg.ProtectedLabel] == "true" {
+		return fmt.Errorf("namespace %s is protected (%s=true); remove the label to delete it", ns.Name, namespacepkg.ProtectedLabel)
+	}
+	if owner := namespacepkg.Owner(ns); owner != username {
+		return fmt.Errorf("namespace %s belongs to %s", ns.Name, owner)
+	}
+
+	// Named environments bind the ServiceAccount of the default one
+	if ns.Name == namespacepkg.Name(username, "") {
+		count, err := namespacepkg.CountEnvs(clientset, username)
+		if err != nil {
+			return err
+		}
+		if count > 1 {
+			return fmt.Errorf("%s still has %d named environments; delete them first (podcraft list --owner %s)", username, count-1, username)
+		}
+	}
+
+	return nil
+}
+
+// confirmDeletion asks for the namespace name to be typed.
+func confirmDeletion(cmd *cobra.Command, namespace string) error {
+
+	fmt.Printf("This deletes namespace %s and all its workloads and data.\nType the namespace name to confirm: ", namespace)
+
+	answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if strings.TrimSpace(answer) != namespace {
+		return fmt.Errorf("confirmation did not match; nothing deleted")
+	}
+
+	return nil
+}
+
+// removeEnvironment deletes the namespace of an environment, unless it is
+// already terminating, and removes its generated kubeconfig.
+func removeEnvironment(clientset *kubernetes.Clientset, ns *corev1.Namespace) error {
+
+	if ns.DeletionTimestamp == nil {
+		err := kube.Retry(func() error {
+			return clientset.CoreV1().
+				Namespaces().
+				Delete(context.Background(), ns.Name, metav1.DeleteOptions{})
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		fmt.Println("Deleted namespace:", ns.Name)
+	} else {
+		fmt.Println("Namespace already terminating:", ns.Name)
+	}
+
+	// The credentials of a deleted environment are useless
+	for _, recipients := range []string{"", "age"} {
+		path := kubeconfigpkg.Options{Dir: kubeconfigDir, EncryptTo: recipients, Cluster: clusterName}.Path(ns.Name)
+		err := os.Remove(path)
+		if err == nil {
+			fmt.Println("Removed kubeconfig:", path)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(deleteCmd)
+	deleteCmd.RunE = onEachCluster(deleteCmd.RunE)
+	deleteCmd.Flags().BoolVar(&allClusters, "all-clusters", false, "Run on every registered cluster")
+	deleteCmd.Flags().StringVar(&envName, "env", "", "Named environment to delete")
+	deleteCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Delete without asking for confirmation")
+	deleteCmd.Flags().BoolVar(&waitDeleted, "wait", false, "Wait until the namespace has terminated")
+	deleteCmd.Flags().DurationVar(&waitTimeout, "wait-timeout", 5*time.Minute, "How long --wait waits for termination")
+	deleteCmd.Flags().BoolVar(&archive, "archive", false, "Export the environment to a bundle before deleting it")
+	deleteCmd.Flags().StringVarP(&bundleFile, "output", "o", "", "Bundle file written by --archive (default <username>[-<env>]-<timestamp>.tar.gz)")
+	deleteCmd.Flags().StringVar(&kubeconfigDir, "kubeconfig-dir", ".", "Directory the developer kubeconfig was written to")
 }
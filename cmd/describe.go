@@ -3,61 +3,107 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+	"github.com/sarthakK31/podcraft/pkg/spec"
 )
 
 var describeCmd = &cobra.Command{
 	Use:   "describe [username]",
 	Short: "Describe developer namespace",
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 
 		username := args[0]
-		namespace := "dev-" + username
 
-		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+		err := validateEnvironment(username, envName)
 		if err != nil {
-			panic(err)
+			return err
 		}
 
-		clientset, err := kubernetes.NewForConfig(config)
+		namespace := namespacepkg.Name(username, envName)
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
 		if err != nil {
-			panic(err)
+			return err
 		}
 
 		ctx := context.Background()
 
 		// Check namespace exists
-		_, err = clientset.CoreV1().
+		ns, err := clientset.CoreV1().
 			Namespaces().
 			Get(ctx, namespace, metav1.GetOptions{})
 
 		if err != nil {
 			if apierrors.IsNotFound(err) {
 				fmt.Println("Namespace does not exist:", namespace)
-				return
+				return nil
 			}
-			panic(err)
+			return err
 		}
 
 		fmt.Println("====================================")
 		fmt.Println("Namespace:", namespace)
 		fmt.Println("====================================")
+		fmt.Println("Owner:      ", username)
+		fmt.Println("Environment:", namespacepkg.Env(ns))
+		if status := ns.Labels[namespacepkg.StatusLabel]; status != "" {
+			fmt.Println("Status:     ", status)
+		}
+		if phase := ns.Annotations[namespacepkg.FailedStepAnnotation]; phase != "" {
+			fmt.Println("Failed phase:", phase)
+		}
+		if expires := ns.Annotations[namespacepkg.ExpiresAnnotation]; expires != "" {
+			fmt.Println("Expires:    ", expires)
+		}
+		if level := ns.Labels[namespacepkg.PodSecurityLabel]; level != "" {
+			fmt.Println("Pod security:", level)
+		}
+
+		recorded, _, err := spec.Recorded(ns)
+		if err != nil {
+			return err
+		}
+		if b := recorded.Burst; b != nil {
+			raised := []string{}
+			if b.CPU != "" {
+				raised = append(raised, "cpu "+b.CPU)
+			}
+			if b.Memory != "" {
+				raised = append(raised, "memory "+b.Memory)
+			}
+			if b.Storage != "" {
+				raised = append(raised, "storage "+b.Storage)
+			}
+			if b.MaxPods != 0 {
+				raised = append(raised, fmt.Sprintf("%d pods", b.MaxPods))
+			}
+
+			if b.Active(time.Now()) {
+				fmt.Println("Burst:      ", strings.Join(raised, ", "), "until", b.ExpiresAt)
+			} else {
+				fmt.Println("Burst:      ", strings.Join(raised, ", "), "expired at", b.ExpiresAt, "(restored by the next reconcile)")
+			}
+		}
 
 		// ResourceQuota
-		quota, err := clientset.CoreV1().
+		resourceQuota, err := clientset.CoreV1().
 			ResourceQuotas(namespace).
-			Get(ctx, "dev-quota", metav1.GetOptions{})
+			Get(ctx, quota.QuotaName, metav1.GetOptions{})
 
 		if err == nil {
 			fmt.Println("\nResourceQuota:")
-			for resource, hard := range quota.Status.Hard {
-				used := quota.Status.Used[resource]
+			for resource, hard := range resourceQuota.Status.Hard {
+				used := resourceQuota.Status.Used[resource]
 				fmt.Printf("  %s: %s / %s\n", resource.String(), used.String(), hard.String())
 			}
 		}
@@ -92,9 +138,14 @@ var describeCmd = &cobra.Command{
 		fmt.Println("\n====================================")
 		fmt.Println("End of Report")
 		fmt.Println("====================================")
+
+		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(describeCmd)
+	describeCmd.RunE = onEachCluster(describeCmd.RunE)
+	describeCmd.Flags().BoolVar(&allClusters, "all-clusters", false, "Run on every registered cluster")
+	describeCmd.Flags().StringVar(&envName, "env", "", "Named environment to describe")
 }
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/bundle"
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+)
+
+var bundleFile string
+
+var exportCmd = &cobra.Command{
+	Use:   "export [username]",
+	Short: "Export a developer environment to a portable bundle",
+	Long: `Export a developer environment to a portable bundle.
+
+The bundle is a gzipped tarball holding the PodCraft spec of the environment
+(podcraft.yaml) and the ConfigMaps, PersistentVolumeClaims, Services,
+Deployments and Ingresses the developer created, with namespace, status and
+cluster-specific fields stripped. Objects managed by PodCraft or owned by
+another object are left out, and so are Secrets. PVC definitions are
+exported, not the data in the volumes.`,
+	Example: `  podcraft export aman -o aman.tar.gz
+  podcraft export aman --env feature-x`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		username := args[0]
+
+		err := validateEnvironment(username, envName)
+		if err != nil {
+			return err
+		}
+
+		namespace := namespacepkg.Name(username, envName)
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		fileName := bundleFile
+		if fileName == "" {
+			fileName = bundle.FileName(namespace)
+		}
+
+		return exportBundle(clientset, namespace, fileName)
+	},
+}
+
+// exportBundle writes the bundle of namespace to fileName.
+func exportBundle(clientset *kubernetes.Clientset, namespace, fileName string) error {
+
+	b, err := bundle.Export(clientset, namespace)
+	if err != nil {
+		return err
+	}
+
+	err = bundle.Write(fileName, b)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %s (%d objects) to %s\n", namespace, len(b.Objects), fileName)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&envName, "env", "", "Named environment to export")
+	exportCmd.Flags().StringVarP(&bundleFile, "output", "o", "", "Bundle file to write (default <username>[-<env>]-<timestamp>.tar.gz)")
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/bundle"
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/spec"
+)
+
+var importAs string
+var fromEnv string
+
+var importCmd = &cobra.Command{
+	Use:   "import [bundle]",
+	Short: "Restore a developer environment from an exported bundle",
+	Long: `Restore a developer environment from a bundle written by "podcraft export".
+
+The environment is provisioned from the spec in the bundle like "podcraft
+create" would, then the developer's objects are re-created in it. With --as
+and --env the bundle is restored for another developer or environment;
+service references to the original namespace are rewritten. Objects that
+already exist are left alone.`,
+	Example: `  podcraft import aman.tar.gz
+  podcraft import aman.tar.gz --as dana`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		b, err := bundle.Read(args[0])
+		if err != nil {
+			return err
+		}
+
+		env := b.Spec
+		if importAs != "" {
+			env.Owner = importAs
+		}
+		if cmd.Flags().Changed("env") {
+			env.Env = envName
+		}
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		return restoreEnvironment(clientset, b, env)
+	},
+}
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone [source-username] [target-username]",
+	Short: "Clone a developer environment for another developer",
+	Long: `Clone a developer environment for another developer.
+
+The target environment gets the quota and team of the source environment and
+a copy of the objects the source developer created, as if the source had
+been exported and imported with --as. The target environment has the same
+name as the source unless --env is given.`,
+	Example: `  podcraft clone aman dana
+  podcraft clone aman dana --from-env feature-x --env onboarding`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		err := validateEnvironment(args[0], fromEnv)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		b, err := bundle.Export(clientset, namespacepkg.Name(args[0], fromEnv))
+		if err != nil {
+			return err
+		}
+
+		env := b.Spec
+		env.Owner = args[1]
+		if cmd.Flags().Changed("env") {
+			env.Env = envName
+		}
+
+		return restoreEnvironment(clientset, b, env)
+	},
+}
+
+// restoreEnvironment provisions env and re-creates the objects of the bundle
+// in it. An environment restored for another developer does not inherit the
+// quota burst and expiry of the source.
+func restoreEnvironment(clientset *kubernetes.Clientset, b *bundle.Bundle, env spec.Environment) error {
+
+	if env.Owner != b.Spec.Owner {
+		env.Burst = nil
+		env.ExpiresAt = ""
+	}
+
+	err := provisionEnvironment(clientset, env, false, onFailureRollback, nil)
+	if err != nil {
+		return err
+	}
+
+	namespace := env.Namespace()
+
+	fmt.Printf("\nRestoring %d objects from %s\n", len(b.Objects), b.Spec.Namespace())
+
+	err = b.Restore(clientset, namespace)
+	if err != nil {
+		return fmt.Errorf("environment %s provisioned, but not all objects were restored:\n%w", namespace, err)
+	}
+
+	fmt.Println("Developer environment restored:", namespace)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(cloneCmd)
+
+	for _, c := range []*cobra.Command{importCmd, cloneCmd} {
+		c.Flags().StringVar(&envName, "env", "", "Environment to restore into (default: the environment of the source)")
+		addAdmissionFlags(c)
+		addKubeconfigFlags(c)
+	}
+
+	importCmd.Flags().StringVar(&importAs, "as", "", "Developer to restore the environment for (default: the owner in the bundle)")
+	cloneCmd.Flags().StringVar(&fromEnv, "from-env", "", "Named environment of the source developer to clone")
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/kubeconfigpkg"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+)
+
+var mergeInto string
+var switchContext bool
+
+var kubeconfigCmd = &cobra.Command{
+	Use:   "kubeconfig [username]",
+	Short: "Issue a fresh developer kubeconfig",
+	Long: `Issue a fresh token for an existing developer environment.
+
+By default a standalone kubeconfig is written, like "create" does. With
+--merge-into the cluster, user and context entries are inserted into (or
+updated in) an existing kubeconfig instead, leaving all other entries alone.`,
+	Example: `  podcraft kubeconfig aman
+  podcraft kubeconfig aman --env feature-x
+  podcraft kubeconfig aman --merge-into ~/.kube/config --switch-context`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		username := args[0]
+
+		err := validateEnvironment(username, envName)
+		if err != nil {
+			return err
+		}
+
+		namespace := namespacepkg.Name(username, envName)
+		homeNamespace := namespacepkg.Name(username, "")
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		if mergeInto == "" {
+			err = kubeconfigpkg.Generate(clientset, kubeconfig, kubeContext, homeNamespace, namespace, username, kubeconfigpkg.Options{
+				Dir:       kubeconfigDir,
+				Force:     force,
+				EncryptTo: encryptTo,
+				Cluster:   clusterName,
+			}, nil)
+			if err != nil {
+				return err
+			}
+			return nil
+		}
+
+		devConfig, err := kubeconfigpkg.Build(clientset, kubeconfig, kubeContext, homeNamespace, namespace, username, nil)
+		if err != nil {
+			return err
+		}
+
+		err = kubeconfigpkg.Merge(devConfig, mergeInto, switchContext)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(kubeconfigCmd)
+	addKubeconfigFlags(kubeconfigCmd)
+	kubeconfigCmd.Flags().StringVar(&envName, "env", "", "Named environment to issue the kubeconfig for")
+	kubeconfigCmd.Flags().StringVar(&mergeInto, "merge-into", "", "Merge the credentials into this kubeconfig instead of writing a new file")
+	kubeconfigCmd.Flags().BoolVar(&switchContext, "switch-context", false, "Make the merged context the current-context")
+}
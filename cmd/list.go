@@ -3,41 +3,68 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
 )
 
+var listOwner string
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List developer namespaces",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 
-		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			panic(err)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		if allClusters {
+			fmt.Fprint(w, "CLUSTER\t")
 		}
+		fmt.Fprintln(w, "NAMESPACE\tOWNER\tENV")
 
-		clientset, err := kubernetes.NewForConfig(config)
-		if err != nil {
-			panic(err)
-		}
+		err := forEachCluster(cmd, func(name string) error {
 
-		namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
-		if err != nil {
-			panic(err)
-		}
+			clientset, err := kube.GetClient(kubeconfig, kubeContext)
+			if err != nil {
+				return err
+			}
 
-		for _, ns := range namespaces.Items {
-			if len(ns.Name) > 4 && ns.Name[:4] == "dev-" {
-				fmt.Println(ns.Name)
+			namespaces, err := clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				return err
 			}
-		}
+
+			for _, ns := range namespaces.Items {
+				if len(ns.Name) > 4 && ns.Name[:4] == "dev-" {
+
+					owner := namespacepkg.Owner(&ns)
+
+					if listOwner != "" && owner != listOwner {
+						continue
+					}
+
+					if allClusters {
+						fmt.Fprintf(w, "%s\t", name)
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\n", ns.Name, owner, namespacepkg.Env(&ns))
+				}
+			}
+
+			return nil
+		})
+
+		w.Flush()
+
+		return err
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().BoolVar(&allClusters, "all-clusters", false, "List environments on every registered cluster")
+	listCmd.Flags().StringVar(&listOwner, "owner", "", "Only list environments of this developer")
 }
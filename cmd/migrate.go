@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/migrate"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+)
+
+var migrateDryRun bool
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate [username]",
+	Short: "Convert a legacy developer environment to the current layout",
+	Long: `Convert a developer environment created by an earlier PodCraft version or
+by the kind/dev-pods manifests to the current managed objects.
+
+Known legacy layouts are detected and shown as a plan before anything is
+changed:
+
+  - deny-all and allow-shared NetworkPolicies, and namespaces they select by
+    the name label
+  - a dev-quota ResourceQuota with requests.* keys
+  - the dev-limits LimitRange
+  - namespaces and objects without PodCraft labels
+
+New objects are applied before the legacy objects they replace are deleted,
+so running pods are not disrupted. The quota keeps the legacy limits;
+--cpu, --memory and --max-pods only apply when there is no quota. Migrate the
+default environment before named environments, which share its identity.`,
+	Example: `  podcraft migrate aman --dry-run
+  podcraft migrate aman`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		username := args[0]
+
+		err := validateEnvironment(username, envName)
+		if err != nil {
+			return err
+		}
+
+		err = validateQuota(cpuLimit, memoryLimit, maxPods)
+		if err != nil {
+			return err
+		}
+
+		namespace := namespacepkg.Name(username, envName)
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		plan, err := migrate.Detect(clientset, namespace, username, envName, migrate.Quota{
+			CPU:     cpuLimit,
+			Memory:  memoryLimit,
+			Storage: quota.DefaultStorage,
+			MaxPods: maxPods,
+		})
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("namespace %s does not exist", namespace)
+		}
+		if err != nil {
+			return err
+		}
+
+		plan.Print()
+
+		if len(plan.Steps) == 0 || migrateDryRun {
+			return nil
+		}
+
+		fmt.Println()
+
+		err = plan.Apply()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("\nEnvironment migrated:", namespace)
+		fmt.Printf("Run \"podcraft kubeconfig %s\" to issue credentials for the developer.\n", username)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().StringVar(&envName, "env", "", "Named environment to migrate")
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Only show the migration plan")
+	migrateCmd.Flags().StringVar(&cpuLimit, "cpu", "2", "Total CPU limit for namespace")
+	migrateCmd.Flags().StringVar(&memoryLimit, "memory", "2Gi", "Total memory limit for namespace")
+	migrateCmd.Flags().IntVar(&maxPods, "max-pods", 10, "Maximum number of pods")
+}
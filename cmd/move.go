@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/bundle"
+	"github.com/sarthakK31/podcraft/pkg/cluster"
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+)
+
+// SuspendedReplicasAnnotation records the replicas of a Deployment scaled to
+// zero by move --suspend.
+const SuspendedReplicasAnnotation = "podcraft.dev/suspended-replicas"
+
+var moveFrom string
+var moveTo string
+var moveSuspend bool
+
+var moveCmd = &cobra.Command{
+	Use:   "move [username]",
+	Short: "Move a developer environment to another cluster",
+	Long: `Move a developer environment between registered clusters.
+
+The environment is provisioned on the target cluster with the quota and team
+of the source, the developer's objects are copied as by "podcraft export" and
+"podcraft import", together with their Secrets, and a kubeconfig for the
+target is issued. Only then is the source deleted, or with --suspend scaled
+to zero and labelled podcraft.dev/status=suspended. The data in
+PersistentVolumeClaims is not copied, and neither are objects of other kinds
+such as Pods, StatefulSets, Jobs and CronJobs; they are listed before the
+source is deleted.`,
+	Example: `  podcraft move aman --from staging --to dev
+  podcraft move aman --env feature-x --from staging --to dev --suspend`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		username := args[0]
+
+		err := validateEnvironment(username, envName)
+		if err != nil {
+			return err
+		}
+
+		if moveFrom == "" || moveTo == "" {
+			return fmt.Errorf("--from and --to are required")
+		}
+		if moveFrom == moveTo {
+			return fmt.Errorf("--from and --to must be different clusters")
+		}
+		for _, flag := range []string{"cluster", "kubeconfig", "context"} {
+			if cmd.Flags().Changed(flag) {
+				return fmt.Errorf("--%s cannot be used with move; the clusters are given by --from and --to", flag)
+			}
+		}
+
+		namespace := namespacepkg.Name(username, envName)
+
+		registry, err := cluster.Load(clustersFile)
+		if err != nil {
+			return err
+		}
+
+		// -------------------------
+		// Source
+		// -------------------------
+
+		err = selectCluster(cmd, registry, moveFrom)
+		if err != nil {
+			return err
+		}
+
+		source, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		ns, err := source.CoreV1().
+			Namespaces().
+			Get(context.Background(), namespace, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("cluster %s: %w", moveFrom, err)
+		}
+
+		b, err := bundle.Export(source, namespace)
+		if err != nil {
+			return err
+		}
+
+		// The bundle is never written, so Secrets can be copied too
+		err = b.AddSecrets(source, namespace)
+		if err != nil {
+			return err
+		}
+
+		uncopied, err := bundle.Uncopied(source, namespace)
+		if err != nil {
+			return err
+		}
+
+		claims := []string{}
+		for _, obj := range b.Objects {
+			if obj.Kind == "PersistentVolumeClaim" {
+				claims = append(claims, obj.Name)
+			}
+		}
+
+		if !moveSuspend {
+			err = checkDeletable(source, ns, username)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Moving %s from %s to %s.\n", namespace, moveFrom, moveTo)
+			if len(claims) > 0 {
+				fmt.Println("WARNING: the data of these PersistentVolumeClaims is not copied and is deleted with the source (use --suspend to keep it):")
+				for _, name := range claims {
+					fmt.Println("  -", name)
+				}
+			}
+			if len(uncopied) > 0 {
+				fmt.Println("WARNING: these objects are not copied and are deleted with the source (use --suspend to keep them):")
+				for _, obj := range uncopied {
+					fmt.Println("  -", obj)
+				}
+			}
+			if !assumeYes {
+				err = confirmDeletion(cmd, namespace)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// -------------------------
+		// Target
+		// -------------------------
+
+		err = selectCluster(cmd, registry, moveTo)
+		if err != nil {
+			return err
+		}
+
+		target, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\n=== Provisioning %s on %s ===\n", namespace, moveTo)
+
+		err = restoreEnvironment(target, b, b.Spec)
+		if err != nil {
+			return fmt.Errorf("%w\n%s on %s was left untouched", err, namespace, moveFrom)
+		}
+
+		// -------------------------
+		// Retire the source
+		// -------------------------
+
+		err = selectCluster(cmd, registry, moveFrom)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\n=== Retiring %s on %s ===\n", namespace, moveFrom)
+
+		if moveSuspend {
+			err = suspendEnvironment(source, namespace)
+		} else {
+			err = removeEnvironment(source, ns)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\nDeveloper environment moved to %s: %s\n", moveTo, namespace)
+
+		if len(claims) > 0 {
+			fmt.Println("\nPersistentVolumeClaims needing manual data migration:")
+			for _, name := range claims {
+				fmt.Println("  -", name)
+			}
+		}
+
+		if len(uncopied) > 0 {
+			fmt.Println("\nObjects not copied, to re-create on", moveTo+":")
+			for _, obj := range uncopied {
+				fmt.Println("  -", obj)
+			}
+		}
+
+		return nil
+	},
+}
+
+// suspendEnvironment scales every Deployment of namespace to zero, recording
+// its replicas, and marks the environment suspended.
+func suspendEnvironment(clientset *kubernetes.Clientset, namespace string) error {
+
+	ctx := context.Background()
+
+	deployments := clientset.AppsV1().Deployments(namespace)
+
+	list, err := deployments.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, d := range list.Items {
+		if d.Spec.Replicas != nil && *d.Spec.Replicas == 0 {
+			continue
+		}
+
+		err = kube.Retry(func() error {
+			live, err := deployments.Get(ctx, d.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			replicas := int32(1)
+			if live.Spec.Replicas != nil {
+				replicas = *live.Spec.Replicas
+			}
+
+			if live.Annotations == nil {
+				live.Annotations = map[string]string{}
+			}
+			live.Annotations[SuspendedReplicasAnnotation] = strconv.Itoa(int(replicas))
+
+			zero := int32(0)
+			live.Spec.Replicas = &zero
+
+			_, err = deployments.Update(ctx, live, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Deployment scaled to zero:", d.Name)
+	}
+
+	err = namespacepkg.Update(clientset, namespace, func(ns *corev1.Namespace) {
+		ns.Labels[namespacepkg.StatusLabel] = namespacepkg.StatusSuspended
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Namespace suspended:", namespace)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(moveCmd)
+	moveCmd.Flags().StringVar(&envName, "env", "", "Named environment to move")
+	moveCmd.Flags().StringVar(&moveFrom, "from", "", "Registered cluster to move the environment from")
+	moveCmd.Flags().StringVar(&moveTo, "to", "", "Registered cluster to move the environment to")
+	moveCmd.Flags().BoolVar(&moveSuspend, "suspend", false, "Scale the source to zero instead of deleting it")
+	moveCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Delete the source without asking for confirmation")
+	addAdmissionFlags(moveCmd)
+	addKubeconfigFlags(moveCmd)
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/kubeconfigpkg"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/network"
+	"github.com/sarthakK31/podcraft/pkg/provision"
+	"github.com/sarthakK31/podcraft/pkg/prune"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+	"github.com/sarthakK31/podcraft/pkg/rbac"
+	"github.com/sarthakK31/podcraft/pkg/spec"
+)
+
+// What create does when a provisioning phase fails.
+const (
+	onFailureRollback = "rollback"
+	onFailureDegraded = "degraded"
+)
+
+// newPipeline returns the provisioning phases of an environment. Guardrails
+// (network isolation and quota) are in effect before the developer's identity
+// is bound, and credentials are issued last. Phases register what they newly
+// create so a failed create can be rolled back. Stale managed objects are
+// only listed unless pruneStale is set. An active quota burst is applied
+// instead of the environment's quota; an expired one is dropped, restoring
+// the quota.
+func newPipeline(clientset *kubernetes.Clientset, env spec.Environment, opts kubeconfigpkg.Options, pruneStale bool, r *kube.Reporter) *provision.Pipeline {
+
+	p := &provision.Pipeline{}
+
+	namespace := env.Namespace()
+	homeNamespace := env.HomeNamespace()
+	labels := kube.Labels(env.Owner, env.Team)
+
+	now := time.Now()
+	if env.Burst != nil && !env.Burst.Active(now) {
+		r.Printf("Quota burst of %s expired at %s; restoring quota\n", namespace, env.Burst.ExpiresAt)
+		env.Burst = nil
+	}
+	limits := env.Effective(now)
+
+	p.Phases = []provision.Phase{
+		{
+			// Creating Namespace (Idempotent)
+			Name: "namespace",
+			Run: func() error {
+				created, err := namespacepkg.EnsureNamespace(clientset, namespace, env.Owner, env.Env, env.Team, env.PodSecurity, r)
+				if err != nil {
+					return err
+				}
+				if created {
+					p.Created("namespace "+namespace, func() error {
+						return clientset.CoreV1().
+							Namespaces().
+							Delete(context.Background(), namespace, metav1.DeleteOptions{})
+					})
+				}
+				return nil
+			},
+			Ready: func() (bool, error) {
+				return namespacepkg.Ready(clientset, namespace)
+			},
+		},
+		{
+			// Applying Network Policies and ResourceQuota
+			Name: "guardrails",
+			Run: func() error {
+				err := network.EnsureNetwork(clientset, namespace, labels, r)
+				if err != nil {
+					return err
+				}
+				r.Println("NetworkPolicies applied")
+
+				return quota.EnsureQuota(clientset, namespace, limits.CPU, limits.Memory, limits.Storage, limits.MaxPods, labels, r)
+			},
+			Ready: func() (bool, error) {
+				ready, err := network.Ready(clientset, namespace)
+				if err != nil || !ready {
+					return ready, err
+				}
+				return quota.Ready(clientset, namespace)
+			},
+		},
+		{
+			// Creating RBAC (Idempotent) - service-account, role, rolebinding
+			Name: "identity",
+			Run: func() error {
+				return rbac.EnsureRBAC(clientset, namespace, env.Owner, homeNamespace, rbac.Developer, labels, r)
+			},
+			Ready: func() (bool, error) {
+				return rbac.Ready(clientset, namespace, env.Owner, homeNamespace)
+			},
+		},
+		{
+			// Removing managed objects no longer in the desired set
+			Name: "prune",
+			Run: func() error {
+				return prune.Prune(clientset, namespace, desiredObjects(env.Owner), pruneStale, r)
+			},
+		},
+		{
+			// Generating kubeconfig for the user and loading Service account token
+			Name: "credentials",
+			Run: func() error {
+				fileName := opts.Path(namespace)
+				_, statErr := os.Stat(fileName)
+
+				err := kubeconfigpkg.Generate(clientset, kubeconfig, kubeContext, homeNamespace, namespace, env.Owner, opts, r)
+				if err != nil {
+					return err
+				}
+				if os.IsNotExist(statErr) {
+					p.Created("kubeconfig "+fileName, func() error {
+						return os.Remove(fileName)
+					})
+				}
+				return nil
+			},
+		},
+		{
+			// Recording the settings and outcome once everything is in place
+			Name: "post-hooks",
+			Run: func() error {
+				record, err := env.Record()
+				if err != nil {
+					return err
+				}
+				err = namespacepkg.Update(clientset, namespace, func(ns *corev1.Namespace) {
+					ns.Annotations[namespacepkg.SpecAnnotation] = record
+					if env.ExpiresAt != "" {
+						ns.Annotations[namespacepkg.ExpiresAnnotation] = env.ExpiresAt
+					}
+					if env.Burst != nil {
+						ns.Annotations[namespacepkg.BurstExpiresAnnotation] = env.Burst.ExpiresAt
+					} else {
+						delete(ns.Annotations, namespacepkg.BurstExpiresAnnotation)
+					}
+				})
+				if err != nil {
+					return err
+				}
+				return namespacepkg.SetStatus(clientset, namespace, namespacepkg.StatusReady, "")
+			},
+		},
+	}
+
+	return p
+}
+
+// desiredObjects returns the managed objects of a namespace whose members
+// are the given developers.
+func desiredObjects(members ...string) prune.Desired {
+
+	desired := prune.Desired{
+		prune.NetworkPolicy: network.PolicyNames,
+		prune.ResourceQuota: {quota.QuotaName},
+		prune.LimitRange:    {quota.LimitRangeName},
+		prune.Role:          {},
+		prune.RoleBinding:   {},
+	}
+
+	for _, member := range members {
+		if member == "" {
+			continue
+		}
+		desired[prune.Role] = append(desired[prune.Role], rbac.RoleName(member))
+		desired[prune.RoleBinding] = append(desired[prune.RoleBinding], rbac.BindingName(member))
+	}
+
+	return desired
+}
+
+// runPipeline runs the pipeline from the given phase ("" for all). On failure
+// it rolls back what this run created if onFailure is rollback, and marks an
+// environment that is left behind as degraded so repair can resume it.
+func runPipeline(clientset *kubernetes.Clientset, p *provision.Pipeline, env spec.Environment, from string, onFailure string, r *kube.Reporter) error {
+
+	namespace := env.Namespace()
+
+	err := p.Run(from)
+	if err == nil {
+		return nil
+	}
+
+	var failed *provision.FailedError
+	if !errors.As(err, &failed) {
+		return err
+	}
+
+	if onFailure == onFailureRollback && p.CanRollback() {
+		r.Println("Provisioning failed, rolling back:", err)
+		if rollbackErr := p.Rollback(r); rollbackErr != nil {
+			return fmt.Errorf("%w; %v", err, rollbackErr)
+		}
+	}
+
+	// A namespace that existed before this run is not rolled back
+	ns, getErr := clientset.CoreV1().
+		Namespaces().
+		Get(context.Background(), namespace, metav1.GetOptions{})
+	if getErr != nil || ns.DeletionTimestamp != nil {
+		return err
+	}
+
+	statusErr := namespacepkg.SetStatus(clientset, namespace, namespacepkg.StatusDegraded, failed.Phase)
+	if statusErr != nil {
+		return fmt.Errorf("%w; marking environment degraded: %v", err, statusErr)
+	}
+
+	repair := "podcraft repair " + env.Owner
+	if env.Env != "" && env.Env != namespacepkg.DefaultEnv {
+		repair += " --env " + env.Env
+	}
+
+	return fmt.Errorf("%w\n%s marked %s; run \"%s\" to resume", err, namespace, namespacepkg.StatusDegraded, repair)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sarthakK31/podcraft/pkg/network"
+	"github.com/sarthakK31/podcraft/pkg/prune"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+)
+
+func TestDesiredObjects(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		members  []string
+		roles    []string
+		bindings []string
+	}{
+		{"developer", []string{"aman"}, []string{"aman-role"}, []string{"aman-binding"}},
+		{"team without lead", []string{"aman", "dana", ""}, []string{"aman-role", "dana-role"}, []string{"aman-binding", "dana-binding"}},
+		{"no members", nil, []string{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := prune.Desired{
+				prune.NetworkPolicy: network.PolicyNames,
+				prune.ResourceQuota: {quota.QuotaName},
+				prune.LimitRange:    {quota.LimitRangeName},
+				prune.Role:          tt.roles,
+				prune.RoleBinding:   tt.bindings,
+			}
+			if got := desiredObjects(tt.members...); !reflect.DeepEqual(got, want) {
+				t.Errorf("desiredObjects(%q) = %v, want %v", tt.members, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/bundle"
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/kubeconfigpkg"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+	"github.com/sarthakK31/podcraft/pkg/spec"
+	"github.com/sarthakK31/podcraft/pkg/team"
+)
+
+var reconcileAll bool
+var reconcileSelector string
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Re-apply the PodCraft baseline to existing environments",
+	Long: `Re-apply the network policies, quota and RBAC of PodCraft to every
+namespace labelled podcraft.dev/managed=true, e.g. after upgrading PodCraft.
+
+Environments are reconciled with the settings recorded on them when they
+were provisioned, not with flag defaults. Environments provisioned before
+settings were recorded are reconciled with their current labels and quota,
+which are then recorded. Expired quota bursts are ended, restoring the
+quota. Team namespaces are reconciled like "podcraft team create" with
+their recorded members and current quota. Kubeconfigs are not reissued,
+and suspended environments are skipped. A report lists every namespace
+with the objects that were created, updated or had drifted from the
+desired state.`,
+	Example: `  podcraft reconcile --all
+  podcraft reconcile --selector podcraft.dev/team=payments --workers 8`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if reconcileAll == (reconcileSelector != "") {
+			return fmt.Errorf("exactly one of --all and --selector is required")
+		}
+		if workers < 1 {
+			return fmt.Errorf("--workers must be at least 1")
+		}
+
+		selector := namespacepkg.ManagedLabel + "=true"
+		if reconcileSelector != "" {
+			_, err := labels.Parse(reconcileSelector)
+			if err != nil {
+				return fmt.Errorf("invalid --selector: %w", err)
+			}
+			selector += "," + reconcileSelector
+		}
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		list, err := clientset.CoreV1().
+			Namespaces().
+			List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return err
+		}
+
+		namespaces := []corev1.Namespace{}
+		for _, ns := range list.Items {
+			if ns.DeletionTimestamp == nil {
+				namespaces = append(namespaces, ns)
+			}
+		}
+
+		if len(namespaces) == 0 {
+			fmt.Println("No environments match", selector)
+			return nil
+		}
+
+		return reconcileEnvironments(clientset, namespaces)
+	},
+}
+
+// reconcileReport is the outcome of reconciling one environment.
+type reconcileReport struct {
+	settings string
+	objects  map[kube.Result][]string
+	skipped  string
+	err      error
+}
+
+// reconcileEnvironments reconciles namespaces with a bounded pool of workers
+// and prints a drift report.
+func reconcileEnvironments(clientset *kubernetes.Clientset, namespaces []corev1.Namespace) error {
+
+	reports := map[string]*reconcileReport{}
+	for _, ns := range namespaces {
+		reports[ns.Name] = &reconcileReport{objects: map[kube.Result][]string{}}
+	}
+
+	// Attribute every reconciled object to its environment
+	var mu sync.Mutex
+	observe := func(obj metav1.Object, result kube.Result) {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = obj.GetName()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		report, ok := reports[namespace]
+		if !ok {
+			return
+		}
+		kind := reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+		report.objects[result] = append(report.objects[result], kind+"/"+obj.GetName())
+	}
+
+	fmt.Printf("Reconciling %d environments with %d workers\n", len(namespaces), workers)
+
+	jobs := make(chan *corev1.Namespace)
+	var wg sync.WaitGroup
+
+	for range min(workers, len(namespaces)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ns := range jobs {
+				r := &kube.Reporter{Prefix: ns.Name + ": ", Observe: observe}
+				reconcileEnvironment(clientset, ns, reports[ns.Name], r)
+			}
+		}()
+	}
+
+	for i := range namespaces {
+		jobs <- &namespaces[i]
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+	drifted := []string{}
+
+	fmt.Println("\nDrift report:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tSETTINGS\tCREATED\tUPDATED\tDRIFTED\tRESULT")
+	for _, ns := range namespaces {
+		report := reports[ns.Name]
+
+		var result string
+		switch {
+		case report.skipped != "":
+			result = "skipped: " + report.skipped
+		case report.err != nil:
+			failed++
+			// Errors can carry a repair hint on further lines
+			result = "failed: " + strings.SplitN(report.err.Error(), "\n", 2)[0]
+		case len(report.objects[kube.Created])+len(report.objects[kube.Updated])+len(report.objects[kube.Drifted]) > 0:
+			result = "repaired"
+		default:
+			result = "in sync"
+		}
+
+		for _, obj := range report.objects[kube.Drifted] {
+			drifted = append(drifted, ns.Name+": "+obj)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n",
+			ns.Name,
+			dash(report.settings),
+			len(report.objects[kube.Created]),
+			len(report.objects[kube.Updated]),
+			len(report.objects[kube.Drifted]),
+			result,
+		)
+	}
+	w.Flush()
+
+	if len(drifted) > 0 {
+		slices.Sort(drifted)
+		fmt.Println("\nObjects changed outside PodCraft and restored:")
+		for _, obj := range drifted {
+			fmt.Println("  -", obj)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d environments failed", failed, len(namespaces))
+	}
+
+	return nil
+}
+
+// reconcileEnvironment re-runs the provisioning pipeline of ns, except for
+// issuing credentials, with its recorded settings. Team namespaces are
+// reconciled like "podcraft team create" with their recorded members.
+func reconcileEnvironment(clientset *kubernetes.Clientset, ns *corev1.Namespace, report *reconcileReport, r *kube.Reporter) {
+
+	if ns.Labels[namespacepkg.StatusLabel] == namespacepkg.StatusSuspended {
+		report.skipped = namespacepkg.StatusSuspended
+		return
+	}
+
+	if ns.Labels[namespacepkg.OwnerLabel] == "" {
+		name := ns.Labels[namespacepkg.TeamLabel]
+		if name == "" || ns.Name != team.Namespace(name) {
+			report.skipped = "neither a developer environment nor a team"
+			return
+		}
+		report.settings = "team"
+		report.err = reconcileTeamNamespace(clientset, ns, name, r)
+		return
+	}
+
+	_, recorded, err := spec.Recorded(ns)
+	if err != nil {
+		report.err = err
+		return
+	}
+	report.settings = "live"
+	if recorded {
+		report.settings = "recorded"
+	}
+
+	env, err := bundle.Spec(clientset, ns.Name)
+	if err != nil {
+		report.err = err
+		return
+	}
+
+	pipeline := newPipeline(clientset, env, kubeconfigpkg.Options{}, pruneStale, r)
+	pipeline.Skip("credentials")
+
+	report.err = runPipeline(clientset, pipeline, env, "", onFailureDegraded, r)
+}
+
+// reconcileTeamNamespace reconciles the team namespace ns with its recorded
+// members and current quota.
+func reconcileTeamNamespace(clientset *kubernetes.Clientset, ns *corev1.Namespace, name string, r *kube.Reporter) error {
+
+	q, err := clientset.CoreV1().
+		ResourceQuotas(ns.Name).
+		Get(context.Background(), quota.QuotaName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("namespace %s has no %s; run \"podcraft team create %s\"", ns.Name, quota.QuotaName, name)
+	}
+	if err != nil {
+		return err
+	}
+
+	cpu := q.Spec.Hard[corev1.ResourceLimitsCPU]
+	memory := q.Spec.Hard[corev1.ResourceLimitsMemory]
+	pods := q.Spec.Hard[corev1.ResourcePods]
+
+	t, err := team.Get(clientset, name)
+	if err != nil {
+		return err
+	}
+
+	return reconcileTeam(clientset, t, cpu.String(), memory.String(), int(pods.Value()), r)
+}
+
+func init() {
+	rootCmd.AddCommand(reconcileCmd)
+	reconcileCmd.RunE = onEachCluster(reconcileCmd.RunE)
+	reconcileCmd.Flags().BoolVar(&allClusters, "all-clusters", false, "Run on every registered cluster")
+	reconcileCmd.Flags().BoolVar(&reconcileAll, "all", false, "Reconcile every managed namespace")
+	reconcileCmd.Flags().StringVar(&reconcileSelector, "selector", "", "Only reconcile managed namespaces matching this label selector")
+	reconcileCmd.Flags().IntVar(&workers, "workers", 4, "Number of environments reconciled concurrently")
+	reconcileCmd.Flags().BoolVar(&pruneStale, "prune", false, "Delete PodCraft-managed objects no longer in the desired set")
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/kubeconfigpkg"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/spec"
+)
+
+var repairCmd = &cobra.Command{
+	Use:   "repair [username]",
+	Short: "Resume provisioning of a degraded developer environment",
+	Long: `Resume provisioning of a degraded developer environment.
+
+When "create --on-failure=degraded" fails, or a failed create could not be
+rolled back, the namespace is labelled podcraft.dev/status=degraded with the
+failed phase recorded. repair re-runs provisioning from that phase.`,
+	Example: `  podcraft repair aman
+  podcraft repair aman --env feature-x`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		username := args[0]
+
+		err := validateEnvironment(username, envName)
+		if err != nil {
+			return err
+		}
+
+		err = validateQuota(cpuLimit, memoryLimit, maxPods)
+		if err != nil {
+			return err
+		}
+
+		namespace := namespacepkg.Name(username, envName)
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		ns, err := clientset.CoreV1().
+			Namespaces().
+			Get(context.Background(), namespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("namespace %s does not exist; use \"podcraft create\"", namespace)
+		}
+		if err != nil {
+			return err
+		}
+
+		if ns.Labels[namespacepkg.StatusLabel] != namespacepkg.StatusDegraded {
+			fmt.Println("Environment is not degraded:", namespace)
+			return nil
+		}
+
+		failedPhase := ns.Annotations[namespacepkg.FailedStepAnnotation]
+
+		env := spec.Environment{
+			Owner:   username,
+			Env:     envName,
+			Team:    ns.Labels[namespacepkg.TeamLabel],
+			CPU:     cpuLimit,
+			Memory:  memoryLimit,
+			MaxPods: maxPods,
+		}
+
+		// Settings are recorded once provisioning succeeds, but an
+		// environment that failed late may already have its quota
+		recorded, ok, err := recordedEnvironment(clientset, namespace)
+		if err != nil {
+			return err
+		}
+		if ok {
+			env.CPU = recorded.CPU
+			env.Memory = recorded.Memory
+			env.MaxPods = recorded.MaxPods
+			env.Storage = recorded.Storage
+			env.PodSecurity = recorded.PodSecurity
+			env.ExpiresAt = recorded.ExpiresAt
+			env.Burst = recorded.Burst
+			applySettingFlags(cmd, &env)
+		}
+
+		pipeline := newPipeline(clientset, env, kubeconfigpkg.Options{
+			Dir:       kubeconfigDir,
+			Force:     force,
+			EncryptTo: encryptTo,
+			Cluster:   clusterName,
+		}, pruneStale, nil)
+
+		// Phases recorded by older versions are unknown; re-run everything
+		if !pipeline.Has(failedPhase) {
+			failedPhase = ""
+			fmt.Println("Re-running all phases of", namespace)
+		} else {
+			fmt.Printf("Resuming %s from phase %s\n", namespace, failedPhase)
+		}
+
+		err = runPipeline(clientset, pipeline, env, failedPhase, onFailureDegraded, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Developer environment repaired:", namespace)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(repairCmd)
+	repairCmd.Flags().StringVar(&envName, "env", "", "Named environment to repair")
+	repairCmd.Flags().StringVar(&cpuLimit, "cpu", "2", "Total CPU limit for namespace")
+	repairCmd.Flags().StringVar(&memoryLimit, "memory", "2Gi", "Total memory limit for namespace")
+	repairCmd.Flags().IntVar(&maxPods, "max-pods", 10, "Maximum number of pods")
+	repairCmd.Flags().BoolVar(&pruneStale, "prune", false, "Delete PodCraft-managed objects no longer in the desired set")
+	addKubeconfigFlags(repairCmd)
+}
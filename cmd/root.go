@@ -5,11 +5,15 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/sarthakK31/podcraft/pkg/cluster"
+	"github.com/sarthakK31/podcraft/pkg/kube"
 )
 
 var Version = "v0.1.2"
 
 var kubeconfig string
+var kubeContext string
 
 var rootCmd = &cobra.Command{
 	Use:   "podcraft",
@@ -38,16 +42,39 @@ Examples:
   Create with custom limits:
     podcraft create alice --cpu=4 --memory=4Gi --max-pods=20
 
+  Create a named environment (dev-alice-feature-x):
+    podcraft create alice --env feature-x
+
   Delete developer environment:
     podcraft delete alice
 
   Describe developer namespace:
     podcraft describe alice
 
+  List environments on every registered cluster:
+    podcraft list --all-clusters
+
 Use "podcraft [command] --help" for more information about a command.
 `,
 	SilenceUsage:  true,
 	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+
+		if clusterName == "" {
+			return nil
+		}
+
+		if allClusters {
+			return fmt.Errorf("--cluster and --all-clusters are mutually exclusive")
+		}
+
+		registry, err := cluster.Load(clustersFile)
+		if err != nil {
+			return err
+		}
+
+		return selectCluster(cmd, registry, clusterName)
+	},
 }
 
 func Execute() {
@@ -64,6 +91,48 @@ func init() {
 		os.Getenv("HOME")+"/.kube/config",
 		"Path to admin kubeconfig file",
 	)
+	rootCmd.PersistentFlags().StringVar(
+		&kubeContext,
+		"context",
+		"",
+		"Kubeconfig context to use (defaults to current-context)",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&clusterName,
+		"cluster",
+		"",
+		"Registered cluster to use (see podcraft cluster)",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&clustersFile,
+		"clusters-file",
+		cluster.DefaultPath(),
+		"Path to the cluster registry",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&kube.ForceConflicts,
+		"force-conflicts",
+		true,
+		"Take over fields of PodCraft objects owned by other field managers",
+	)
+	rootCmd.PersistentFlags().Float32Var(
+		&kube.QPS,
+		"qps",
+		kube.QPS,
+		"Maximum requests per second to the API server",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&kube.Burst,
+		"burst",
+		kube.Burst,
+		"Maximum burst of requests to the API server",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&kube.Timeout,
+		"timeout",
+		kube.Timeout,
+		"Timeout of each API call (0 for none)",
+	)
 
 	rootCmd.SetVersionTemplate("PodCraft {{.Version}}\n")
 	rootCmd.Version = Version
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/roster"
+	"github.com/sarthakK31/podcraft/pkg/spec"
+	"github.com/sarthakK31/podcraft/pkg/validate"
+)
+
+var rosterFile string
+var workers int
+
+// createFromRoster provisions every developer of the roster file with a
+// bounded pool of workers and prints a summary. Every entry is validated
+// before anything is provisioned. Existing environments keep their recorded
+// settings unless the roster entry or a flag gives them. Environments are
+// admitted one at a time, counting those admitted before them, so workers
+// cannot together exceed a budget or cluster capacity. Output lines are
+// prefixed with the developer.
+func createFromRoster(cmd *cobra.Command, clientset *kubernetes.Clientset) error {
+
+	if workers < 1 {
+		return fmt.Errorf("--workers must be at least 1")
+	}
+
+	entries, err := roster.Load(rosterFile)
+	if err != nil {
+		return err
+	}
+
+	envs := make([]spec.Environment, len(entries))
+
+	for i, entry := range entries {
+		env := spec.Environment{
+			Owner:       entry.User,
+			Env:         envName,
+			Team:        teamName,
+			CPU:         cpuLimit,
+			Memory:      memoryLimit,
+			MaxPods:     maxPods,
+			Storage:     storageLimit,
+			PodSecurity: podSecurity,
+		}
+
+		err = validateEnvironment(env.Owner, env.Env)
+		if err != nil {
+			return err
+		}
+
+		recorded, ok, err := recordedEnvironment(clientset, env.Namespace())
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.User, err)
+		}
+		if ok {
+			env = keepRecorded(cmd, env, recorded)
+		}
+
+		if entry.Team != "" {
+			env.Team = entry.Team
+		}
+
+		if entry.Profile != "" {
+			profile, ok := roster.Profiles[entry.Profile]
+			if !ok {
+				return fmt.Errorf("%s: unknown profile %q (profiles: %s)", entry.User, entry.Profile, profileNames())
+			}
+			env.CPU = profile.CPU
+			env.Memory = profile.Memory
+			env.MaxPods = profile.MaxPods
+		}
+
+		if entry.TTL != "" {
+			ttl, err := roster.ParseTTL(entry.TTL)
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry.User, err)
+			}
+			env.ExpiresAt = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+		}
+
+		err = validateQuota(env.CPU, env.Memory, env.MaxPods)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.User, err)
+		}
+		err = validateSettings(env.Storage, env.PodSecurity)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.User, err)
+		}
+		if env.Team != "" {
+			err = validate.Name("team", env.Team)
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry.User, err)
+			}
+		}
+
+		envs[i] = env
+	}
+
+	results := make([]error, len(envs))
+	reporters := make([]*kube.Reporter, len(envs))
+	admitted := map[string]admission{}
+
+	for i, env := range envs {
+		reporters[i] = &kube.Reporter{Prefix: env.Owner + ": "}
+
+		env, hard, err := prepareEnvironment(env)
+		if err == nil {
+			err = admitEnvironment(clientset, env, hard, admitted, reporters[i])
+		}
+		if err != nil {
+			results[i] = err
+			continue
+		}
+
+		envs[i] = env
+		admitted[env.Namespace()] = admission{team: env.Team, hard: hard}
+	}
+
+	fmt.Printf("Provisioning %d environments with %d workers\n", len(admitted), workers)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for range min(workers, len(admitted)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = provisionAdmitted(clientset, envs[i], pruneStale, onFailure, reporters[i])
+			}
+		}()
+	}
+
+	for i := range envs {
+		if results[i] == nil {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	failed := 0
+
+	fmt.Println("\nSummary:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "USER\tNAMESPACE\tTEAM\tPROFILE\tEXPIRES\tRESULT")
+	for i, env := range envs {
+		result := "ready"
+		if results[i] != nil {
+			failed++
+			// Errors can carry a repair hint on further lines
+			result = "failed: " + strings.SplitN(results[i].Error(), "\n", 2)[0]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			env.Owner,
+			env.Namespace(),
+			dash(env.Team),
+			dash(entries[i].Profile),
+			dash(env.ExpiresAt),
+			result,
+		)
+	}
+	w.Flush()
+
+	fmt.Println("\nKubeconfigs written to:", kubeconfigDir)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d environments failed", failed, len(envs))
+	}
+
+	return nil
+}
+
+// profileNames returns the names of the roster profiles, sorted.
+func profileNames() string {
+
+	names := make([]string, 0, len(roster.Profiles))
+	for name := range roster.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return strings.Join(names, ", ")
+}
+
+// dash returns s, or "-" if it is empty.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,392 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/budget"
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/network"
+	"github.com/sarthakK31/podcraft/pkg/prune"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+	"github.com/sarthakK31/podcraft/pkg/team"
+	"github.com/sarthakK31/podcraft/pkg/validate"
+)
+
+var teamMembers []string
+var teamLead string
+var teamCPULimit string
+var teamMemoryLimit string
+var teamMaxPods int
+var memberIsLead bool
+var budgetCPU string
+var budgetMemory string
+var budgetStorage string
+var budgetPods int
+var budgetEnforcement string
+
+var teamCmd = &cobra.Command{
+	Use:   "team",
+	Short: "Manage team namespaces",
+	Long: `Manage team namespaces shared by several developers.
+
+A team gets a team-<name> namespace with the same network isolation and quota
+as a developer environment. Each member is bound with the identity of their
+own developer environment, so their existing kubeconfig works in the team
+namespace too. The lead can additionally manage Secrets and ConfigMaps and
+view quotas.`,
+}
+
+var teamCreateCmd = &cobra.Command{
+	Use:     "create [team]",
+	Short:   "Create or reconcile a team namespace",
+	Example: `  podcraft team create payments --members aman,bailey --lead charlie`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		name := args[0]
+		namespace := team.Namespace(name)
+
+		if len(teamMembers) == 0 && teamLead == "" {
+			return fmt.Errorf("a team needs at least one member or a lead")
+		}
+
+		// Validate all input before touching the cluster
+		err := validate.Name("team", name)
+		if err != nil {
+			return err
+		}
+
+		for _, member := range append(teamMembers, teamLead) {
+			if member == "" {
+				continue
+			}
+			err = validate.Name("username", member)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = validateQuota(teamCPULimit, teamMemoryLimit, teamMaxPods)
+		if err != nil {
+			return err
+		}
+
+		hard, err := quota.Hard(teamCPULimit, teamMemoryLimit, quota.DefaultStorage, teamMaxPods)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		// Admit the team quota against the budget before anything is
+		// applied; a team that does not exist yet has no budget
+		err = budget.Check(clientset, name, map[string]corev1.ResourceList{namespace: hard}, nil)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		err = reconcileTeam(clientset, &team.Team{
+			Name:    name,
+			Members: teamMembers,
+			Lead:    teamLead,
+		}, teamCPULimit, teamMemoryLimit, teamMaxPods, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Team environment ready:", namespace)
+
+		return nil
+	},
+}
+
+// reconcileTeam applies the namespace, network isolation and quota of a team
+// and binds its members.
+func reconcileTeam(clientset *kubernetes.Clientset, t *team.Team, cpu, memory string, maxPods int, r *kube.Reporter) error {
+
+	namespace := team.Namespace(t.Name)
+
+	err := team.EnsureNamespace(clientset, t.Name, r)
+	if err != nil {
+		return err
+	}
+
+	labels := kube.Labels("", t.Name)
+
+	err = network.EnsureNetwork(clientset, namespace, labels, r)
+	if err != nil {
+		return err
+	}
+	r.Println("NetworkPolicies applied")
+
+	err = quota.EnsureQuota(clientset, namespace, cpu, memory, quota.DefaultStorage, maxPods, labels, r)
+	if err != nil {
+		return err
+	}
+
+	err = team.Reconcile(clientset, t, r)
+	if err != nil {
+		return err
+	}
+
+	return prune.Prune(clientset, namespace, desiredObjects(append(t.Members, t.Lead)...), pruneStale, r)
+}
+
+var teamAddMemberCmd = &cobra.Command{
+	Use:   "add-member [team] [username]",
+	Short: "Add a developer to a team",
+	Example: `  podcraft team add-member payments dana
+  podcraft team add-member payments dana --lead`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		username := args[1]
+
+		err := validate.Name("username", username)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		t, err := team.Get(clientset, args[0])
+		if err != nil {
+			return err
+		}
+
+		if memberIsLead {
+			// The previous lead stays on the team as a member
+			if t.Lead != "" && t.Lead != username {
+				t.Members = append(t.Members, t.Lead)
+			}
+			t.Lead = username
+			t.Members = slices.DeleteFunc(t.Members, func(m string) bool { return m == username })
+		} else if !slices.Contains(t.Members, username) && t.Lead != username {
+			t.Members = append(t.Members, username)
+		}
+
+		err = team.Reconcile(clientset, t, nil)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+var teamRemoveMemberCmd = &cobra.Command{
+	Use:   "remove-member [team] [username]",
+	Short: "Remove a developer from a team",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		username := args[1]
+
+		err := validate.Name("username", username)
+		if err != nil {
+			return err
+		}
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		t, err := team.Get(clientset, args[0])
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(t.Members, username) && t.Lead != username {
+			fmt.Println(username, "is not a member of team", t.Name)
+			return nil
+		}
+
+		t.Members = slices.DeleteFunc(t.Members, func(m string) bool { return m == username })
+		if t.Lead == username {
+			t.Lead = ""
+		}
+
+		err = team.Reconcile(clientset, t, nil)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+var teamBudgetCmd = &cobra.Command{
+	Use:   "budget [team]",
+	Short: "Set the resource budget of a team",
+	Long: `Set the resource budget of a team.
+
+The budget caps the sum of the quotas of the team namespace and of every
+developer environment created with --team. Only the given resources are
+changed. With --enforcement=warn, create prints a warning instead of
+refusing environments that exceed the budget.`,
+	Example: `  podcraft team budget payments --cpu 16 --memory 32Gi --storage 100Gi --pods 100`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		b := &budget.Budget{
+			Limits: corev1.ResourceList{},
+		}
+
+		if cmd.Flags().Changed("cpu") {
+			if err := validate.Quantity("cpu", budgetCPU); err != nil {
+				return err
+			}
+			b.Limits[corev1.ResourceLimitsCPU] = resource.MustParse(budgetCPU)
+		}
+		if cmd.Flags().Changed("memory") {
+			if err := validate.Quantity("memory", budgetMemory); err != nil {
+				return err
+			}
+			b.Limits[corev1.ResourceLimitsMemory] = resource.MustParse(budgetMemory)
+		}
+		if cmd.Flags().Changed("storage") {
+			if err := validate.Quantity("storage", budgetStorage); err != nil {
+				return err
+			}
+			b.Limits[corev1.ResourceRequestsStorage] = resource.MustParse(budgetStorage)
+		}
+		if cmd.Flags().Changed("pods") {
+			if err := validate.Count("pods", budgetPods); err != nil {
+				return err
+			}
+			b.Limits[corev1.ResourcePods] = *resource.NewQuantity(int64(budgetPods), resource.DecimalSI)
+		}
+		if cmd.Flags().Changed("enforcement") {
+			if budgetEnforcement != budget.Reject && budgetEnforcement != budget.Warn {
+				return fmt.Errorf("--enforcement must be %q or %q", budget.Reject, budget.Warn)
+			}
+			b.Enforcement = budgetEnforcement
+		}
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		err = budget.Set(clientset, args[0], b)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	},
+}
+
+var teamUsageCmd = &cobra.Command{
+	Use:   "usage [team]",
+	Short: "Show quota allocation of a team against its budget",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		name := args[0]
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		b, err := budget.Get(clientset, name)
+		if err != nil {
+			return err
+		}
+
+		allocation, err := budget.Allocation(clientset, name)
+		if err != nil {
+			return err
+		}
+
+		total := budget.Total(allocation)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+
+		fmt.Fprintln(w, "RESOURCE\tALLOCATED\tBUDGET")
+		for _, resourceName := range budget.Resources {
+			allocated := total[resourceName]
+			limit := "-"
+			if q, ok := b.Limits[resourceName]; ok {
+				limit = q.String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", resourceName, allocated.String(), limit)
+		}
+		w.Flush()
+
+		fmt.Println("\nEnforcement:", b.Enforcement)
+
+		namespaces := make([]string, 0, len(allocation))
+		for namespace := range allocation {
+			namespaces = append(namespaces, namespace)
+		}
+		slices.Sort(namespaces)
+
+		fmt.Println("\nNamespaces:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "  NAMESPACE\tCPU\tMEMORY\tSTORAGE\tPODS")
+		for _, namespace := range namespaces {
+			hard := allocation[namespace]
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n",
+				namespace,
+				quantityString(hard, corev1.ResourceLimitsCPU),
+				quantityString(hard, corev1.ResourceLimitsMemory),
+				quantityString(hard, corev1.ResourceRequestsStorage),
+				quantityString(hard, corev1.ResourcePods),
+			)
+		}
+		w.Flush()
+
+		return nil
+	},
+}
+
+// quantityString formats a resource of a list, or "-" if it is not set.
+func quantityString(list corev1.ResourceList, name corev1.ResourceName) string {
+	q, ok := list[name]
+	if !ok {
+		return "-"
+	}
+	return q.String()
+}
+
+func init() {
+	rootCmd.AddCommand(teamCmd)
+	teamCmd.AddCommand(teamCreateCmd)
+	teamCmd.AddCommand(teamAddMemberCmd)
+	teamCmd.AddCommand(teamRemoveMemberCmd)
+	teamCmd.AddCommand(teamBudgetCmd)
+	teamCmd.AddCommand(teamUsageCmd)
+
+	teamCreateCmd.Flags().StringSliceVar(&teamMembers, "members", nil, "Comma-separated developers on the team")
+	teamCreateCmd.Flags().StringVar(&teamLead, "lead", "", "Team lead (can also manage Secrets and ConfigMaps)")
+	teamCreateCmd.Flags().StringVar(&teamCPULimit, "cpu", "4", "Total CPU limit for the team namespace")
+	teamCreateCmd.Flags().StringVar(&teamMemoryLimit, "memory", "4Gi", "Total memory limit for the team namespace")
+	teamCreateCmd.Flags().IntVar(&teamMaxPods, "max-pods", 20, "Maximum number of pods")
+	teamCreateCmd.Flags().BoolVar(&pruneStale, "prune", false, "Delete PodCraft-managed objects no longer in the desired set")
+
+	teamAddMemberCmd.Flags().BoolVar(&memberIsLead, "lead", false, "Make the developer the team lead")
+
+	teamBudgetCmd.Flags().StringVar(&budgetCPU, "cpu", "", "Total CPU limit across the team")
+	teamBudgetCmd.Flags().StringVar(&budgetMemory, "memory", "", "Total memory limit across the team")
+	teamBudgetCmd.Flags().StringVar(&budgetStorage, "storage", "", "Total storage requests across the team")
+	teamBudgetCmd.Flags().IntVar(&budgetPods, "pods", 0, "Total number of pods across the team")
+	teamBudgetCmd.Flags().StringVar(&budgetEnforcement, "enforcement", budget.Reject, "What to do when the budget is exceeded: reject or warn")
+}
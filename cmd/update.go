@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/bundle"
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/kubeconfigpkg"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+	"github.com/sarthakK31/podcraft/pkg/spec"
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update [username]",
+	Short: "Change settings of an existing developer environment",
+	Long: `Change settings of an existing developer environment.
+
+Only the settings given as flags are changed; the others keep the values
+recorded when the environment was provisioned. A quota below what the
+namespace currently uses is refused. The settings before and after the
+update are printed. The kubeconfig is not reissued.`,
+	Example: `  podcraft update aman --cpu 4
+  podcraft update aman --cpu 4 --max-pods 20 --storage 20Gi --pod-security baseline
+  podcraft update aman --env feature-x --memory 4Gi`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		username := args[0]
+
+		err := validateEnvironment(username, envName)
+		if err != nil {
+			return err
+		}
+
+		namespace := namespacepkg.Name(username, envName)
+
+		clientset, err := kube.GetClient(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+
+		before, ok, err := recordedEnvironment(clientset, namespace)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("namespace %s does not exist; use \"podcraft create\"", namespace)
+		}
+
+		env := before
+		if !applySettingFlags(cmd, &env) {
+			return fmt.Errorf("nothing to update; give --cpu, --memory, --storage, --max-pods or --pod-security")
+		}
+
+		err = validateQuota(env.CPU, env.Memory, env.MaxPods)
+		if err != nil {
+			return err
+		}
+
+		err = validateSettings(env.Storage, env.PodSecurity)
+		if err != nil {
+			return err
+		}
+
+		// An active quota burst stays in effect until it expires, for the
+		// limits it raises above the new ones
+		limits := env.Effective(time.Now())
+
+		hard, err := quota.Hard(limits.CPU, limits.Memory, limits.Storage, limits.MaxPods)
+		if err != nil {
+			return err
+		}
+
+		err = quota.CheckUsage(clientset, namespace, hard)
+		if err != nil {
+			return err
+		}
+
+		err = admitEnvironment(clientset, env, hard, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		pipeline := newPipeline(clientset, env, kubeconfigpkg.Options{}, pruneStale, nil)
+		pipeline.Skip("credentials")
+
+		err = runPipeline(clientset, pipeline, env, "", onFailureDegraded, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "SETTING\tBEFORE\tAFTER\tENFORCED")
+		for _, row := range [][4]string{
+			{"cpu", before.CPU, env.CPU, limits.CPU},
+			{"memory", before.Memory, env.Memory, limits.Memory},
+			{"storage", before.Storage, env.Storage, limits.Storage},
+			{"max-pods", strconv.Itoa(before.MaxPods), strconv.Itoa(env.MaxPods), strconv.Itoa(limits.MaxPods)},
+			{"pod-security", dash(before.PodSecurity), dash(env.PodSecurity), dash(env.PodSecurity)},
+		} {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", row[0], row[1], row[2], row[3])
+		}
+		w.Flush()
+
+		if env.Burst.Active(time.Now()) {
+			fmt.Println("\nENFORCED includes the quota burst until", env.Burst.ExpiresAt)
+		}
+
+		fmt.Println("\nDeveloper environment updated:", namespace)
+
+		return nil
+	},
+}
+
+// recordedEnvironment returns the settings of an existing environment, and
+// false if the environment does not exist or has no settings to reuse yet.
+func recordedEnvironment(clientset *kubernetes.Clientset, namespace string) (spec.Environment, bool, error) {
+
+	env, err := bundle.Spec(clientset, namespace)
+
+	// Namespaces that are not PodCraft's, or environments that failed
+	// before their quota was applied, have no settings to reuse yet
+	if apierrors.IsNotFound(err) || errors.Is(err, bundle.ErrNotManaged) {
+		return spec.Environment{}, false, nil
+	}
+	if err != nil {
+		return spec.Environment{}, false, err
+	}
+
+	return env, true, nil
+}
+
+// applySettingFlags overrides the settings of env with the quota, storage
+// and Pod Security flags given on the command line, and reports whether any
+// were given.
+func applySettingFlags(cmd *cobra.Command, env *spec.Environment) bool {
+
+	changed := false
+
+	if cmd.Flags().Changed("cpu") {
+		env.CPU = cpuLimit
+		changed = true
+	}
+	if cmd.Flags().Changed("memory") {
+		env.Memory = memoryLimit
+		changed = true
+	}
+	if cmd.Flags().Changed("storage") {
+		env.Storage = storageLimit
+		changed = true
+	}
+	if cmd.Flags().Changed("max-pods") {
+		env.MaxPods = maxPods
+		changed = true
+	}
+	if cmd.Flags().Changed("pod-security") {
+		env.PodSecurity = podSecurity
+		changed = true
+	}
+
+	return changed
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+	updateCmd.Flags().StringVar(&envName, "env", "", "Named environment to update")
+	updateCmd.Flags().StringVar(&cpuLimit, "cpu", "2", "Total CPU limit for namespace")
+	updateCmd.Flags().StringVar(&memoryLimit, "memory", "2Gi", "Total memory limit for namespace")
+	updateCmd.Flags().IntVar(&maxPods, "max-pods", 10, "Maximum number of pods")
+	updateCmd.Flags().StringVar(&storageLimit, "storage", quota.DefaultStorage, "Total storage requests of PersistentVolumeClaims in the namespace")
+	updateCmd.Flags().StringVar(&podSecurity, "pod-security", "", "Pod Security Standard to enforce: privileged, baseline or restricted, or none to remove it")
+	updateCmd.Flags().BoolVar(&pruneStale, "prune", false, "Delete PodCraft-managed objects no longer in the desired set")
+	addCapacityFlags(updateCmd)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/validate"
+)
+
+// validateEnvironment checks a username and optional environment name
+// before they are turned into object names.
+func validateEnvironment(username, env string) error {
+
+	if err := validate.Name("username", username); err != nil {
+		return err
+	}
+
+	if env != "" && env != namespacepkg.DefaultEnv {
+		if err := validate.Name("environment", env); err != nil {
+			return err
+		}
+	}
+
+	return validate.Namespace(namespacepkg.Name(username, env))
+}
+
+// validateQuota checks quota flags so bad input fails before any API call.
+func validateQuota(cpu, memory string, pods int) error {
+
+	if err := validate.Quantity("cpu", cpu); err != nil {
+		return err
+	}
+
+	if err := validate.Quantity("memory", memory); err != nil {
+		return err
+	}
+
+	return validate.Count("max-pods", pods)
+}
+
+// podSecurityLevels are the Pod Security Standards --pod-security accepts.
+var podSecurityLevels = []string{"privileged", "baseline", "restricted", namespacepkg.PodSecurityNone}
+
+// validateSettings checks the storage quota and Pod Security Standard of an
+// environment, which only create and update take as flags.
+func validateSettings(storage, podSecurity string) error {
+
+	if err := validate.Quantity("storage", storage); err != nil {
+		return err
+	}
+
+	if podSecurity != "" && !slices.Contains(podSecurityLevels, podSecurity) {
+		return fmt.Errorf("--pod-security must be one of %s", strings.Join(podSecurityLevels, ", "))
+	}
+
+	return nil
+}
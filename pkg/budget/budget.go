@@ -0,0 +1,177 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+	"github.com/sarthakK31/podcraft/pkg/team"
+)
+
+const (
+	// LimitAnnotationPrefix prefixes one annotation per budgeted resource on
+	// the team namespace, e.g. budget.podcraft.dev/limits.cpu: "16".
+	LimitAnnotationPrefix = "budget.podcraft.dev/"
+
+	EnforcementAnnotation = "podcraft.dev/budget-enforcement"
+
+	// Reject refuses environments that would exceed the budget.
+	Reject = "reject"
+	// Warn only prints a warning.
+	Warn = "warn"
+)
+
+// Resources are the quota resources a team budget can cap.
+var Resources = []corev1.ResourceName{
+	corev1.ResourceLimitsCPU,
+	corev1.ResourceLimitsMemory,
+	corev1.ResourceRequestsStorage,
+	corev1.ResourcePods,
+}
+
+// Budget caps the sum of the dev-quota hard limits of all namespaces
+// labelled with a team.
+type Budget struct {
+	Limits      corev1.ResourceList
+	Enforcement string
+}
+
+// Get reads the budget of a team. A team without budget annotations has an
+// empty Limits list, which allows anything.
+func Get(clientset *kubernetes.Clientset, teamName string) (*Budget, error) {
+
+	ns, err := clientset.CoreV1().
+		Namespaces().
+		Get(context.Background(), team.Namespace(teamName), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Budget{
+		Limits:      corev1.ResourceList{},
+		Enforcement: ns.Annotations[EnforcementAnnotation],
+	}
+	if b.Enforcement == "" {
+		b.Enforcement = Reject
+	}
+
+	for key, value := range ns.Annotations {
+		name, ok := strings.CutPrefix(key, LimitAnnotationPrefix)
+		if !ok {
+			continue
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget %s=%q on %s: %w", key, value, ns.Name, err)
+		}
+		b.Limits[corev1.ResourceName(name)] = q
+	}
+
+	return b, nil
+}
+
+// Set records the budget on the team namespace. Resources missing from
+// b.Limits keep their current budget.
+func Set(clientset *kubernetes.Clientset, teamName string, b *Budget) error {
+
+	err := namespacepkg.Update(clientset, team.Namespace(teamName), func(ns *corev1.Namespace) {
+		for name, q := range b.Limits {
+			ns.Annotations[LimitAnnotationPrefix+string(name)] = q.String()
+		}
+		if b.Enforcement != "" {
+			ns.Annotations[EnforcementAnnotation] = b.Enforcement
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Budget updated for team:", teamName)
+
+	return nil
+}
+
+// Allocation returns the dev-quota hard limits of every namespace labelled
+// with the team, keyed by namespace.
+func Allocation(clientset *kubernetes.Clientset, teamName string) (map[string]corev1.ResourceList, error) {
+	return quota.HardByNamespace(clientset, namespacepkg.TeamLabel+"="+teamName)
+}
+
+// Total sums the resource lists, counting only budgeted resources.
+func Total(allocation map[string]corev1.ResourceList) corev1.ResourceList {
+
+	total := corev1.ResourceList{}
+
+	for _, hard := range allocation {
+		for _, name := range Resources {
+			q, ok := hard[name]
+			if !ok {
+				continue
+			}
+			sum := total[name]
+			sum.Add(q)
+			total[name] = sum
+		}
+	}
+
+	return total
+}
+
+// Check admits namespaces of the team with the hard limits in admit, keyed
+// by namespace. Their current allocation is replaced before comparing
+// against the budget. Exceeding a budget is an error with Reject enforcement
+// and a warning printed to r with Warn enforcement.
+func Check(clientset *kubernetes.Clientset, teamName string, admit map[string]corev1.ResourceList, r *kube.Reporter) error {
+
+	b, err := Get(clientset, teamName)
+	if err != nil {
+		return err
+	}
+
+	if len(b.Limits) == 0 {
+		return nil
+	}
+
+	allocation, err := Allocation(clientset, teamName)
+	if err != nil {
+		return err
+	}
+	maps.Copy(allocation, admit)
+
+	total := Total(allocation)
+
+	var exceeded []string
+	for _, name := range Resources {
+		limit, ok := b.Limits[name]
+		if !ok {
+			continue
+		}
+		allocated := total[name]
+		if allocated.Cmp(limit) > 0 {
+			exceeded = append(exceeded, fmt.Sprintf("%s %s > %s", name, allocated.String(), limit.String()))
+		}
+	}
+
+	if len(exceeded) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("team %s budget exceeded: %s", teamName, strings.Join(exceeded, ", "))
+
+	if b.Enforcement == Warn {
+		r.Println("WARNING:", message)
+		return nil
+	}
+
+	return errors.New(message)
+}
@@ -0,0 +1,660 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+	"github.com/sarthakK31/podcraft/pkg/spec"
+)
+
+const (
+	// APIVersion and Kind identify the environment spec in a bundle.
+	APIVersion = "podcraft.dev/v1"
+	Kind       = "Environment"
+
+	// SpecFile is the name of the environment spec in a bundle.
+	SpecFile = "podcraft.yaml"
+)
+
+// ErrNotManaged is returned by Spec for a namespace PodCraft does not manage.
+var ErrNotManaged = errors.New("not managed by PodCraft")
+
+// Kinds are the kinds of developer objects a bundle holds, in the order they
+// are restored. Secrets are only held by bundles that are never written, see
+// AddSecrets, as bundle files are not encrypted.
+var Kinds = []string{
+	"Secret",
+	"ConfigMap",
+	"PersistentVolumeClaim",
+	"Service",
+	"Deployment",
+	"Ingress",
+}
+
+// Annotations set by controllers that are meaningless in another namespace.
+var clusterAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.kubernetes.io/selected-node",
+	"volume.kubernetes.io/storage-provisioner",
+	"volume.beta.kubernetes.io/storage-provisioner",
+}
+
+// Manifest is the environment spec as stored in a bundle.
+type Manifest struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	spec.Environment
+}
+
+// Object is a serialized developer object.
+type Object struct {
+	Kind string
+	Name string
+	// Data is the object as YAML, without namespace, status and
+	// cluster-specific fields.
+	Data []byte
+}
+
+// Bundle is a portable copy of a developer environment: its PodCraft spec
+// and the objects the developer created in it.
+type Bundle struct {
+	Spec    spec.Environment
+	Objects []Object
+}
+
+// Export reads the environment spec of namespace and the developer objects
+// in it. Objects managed by PodCraft or by a controller are left out, as they
+// are recreated on restore.
+func Export(clientset *kubernetes.Clientset, namespace string) (*Bundle, error) {
+
+	ctx := context.Background()
+
+	env, err := Spec(clientset, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{Spec: env}
+
+	configMaps, err := clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range configMaps.Items {
+		cm := &configMaps.Items[i]
+		// Published into every namespace by the API server
+		if cm.Name == "kube-root-ca.crt" {
+			continue
+		}
+		err = b.add(cm, corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	claims, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range claims.Items {
+		pvc := &claims.Items[i]
+		// Bound to a volume of this cluster
+		pvc.Spec.VolumeName = ""
+		pvc.Finalizers = nil
+		pvc.Status = corev1.PersistentVolumeClaimStatus{}
+		err = b.add(pvc, corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	services, err := clientset.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		// Allocated by the cluster, except that headless Services stay
+		// headless. Node ports are cluster-wide, so a clone on the same
+		// cluster would collide with the source.
+		if svc.Spec.ClusterIP != corev1.ClusterIPNone {
+			svc.Spec.ClusterIP = ""
+		}
+		svc.Spec.ClusterIPs = nil
+		svc.Spec.HealthCheckNodePort = 0
+		for j := range svc.Spec.Ports {
+			svc.Spec.Ports[j].NodePort = 0
+		}
+		svc.Status = corev1.ServiceStatus{}
+		err = b.add(svc, corev1.SchemeGroupVersion.WithKind("Service"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		d.Status = appsv1.DeploymentStatus{}
+		err = b.add(d, appsv1.SchemeGroupVersion.WithKind("Deployment"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ingresses, err := clientset.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range ingresses.Items {
+		ing := &ingresses.Items[i]
+		ing.Status = networkingv1.IngressStatus{}
+		err = b.add(ing, networkingv1.SchemeGroupVersion.WithKind("Ingress"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// AddSecrets adds the developer Secrets of namespace to the bundle, except
+// ServiceAccount tokens, which are issued per cluster. Write refuses a bundle
+// holding Secrets, so only use it for bundles restored from memory.
+func (b *Bundle) AddSecrets(clientset *kubernetes.Clientset, namespace string) error {
+
+	secrets, err := clientset.CoreV1().Secrets(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if secret.Type == corev1.SecretTypeServiceAccountToken {
+			continue
+		}
+		err = b.add(secret, corev1.SchemeGroupVersion.WithKind("Secret"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Uncopied lists the developer objects in namespace of kinds a bundle does
+// not hold, as "<kind> <name>". Objects managed by PodCraft or owned by
+// another object are left out, like in Export.
+func Uncopied(clientset *kubernetes.Clientset, namespace string) ([]string, error) {
+
+	ctx := context.Background()
+	opts := metav1.ListOptions{}
+
+	lists := []struct {
+		kind string
+		list func() (runtime.Object, error)
+	}{
+		{"Pod", func() (runtime.Object, error) {
+			return clientset.CoreV1().Pods(namespace).List(ctx, opts)
+		}},
+		{"ReplicaSet", func() (runtime.Object, error) {
+			return clientset.AppsV1().ReplicaSets(namespace).List(ctx, opts)
+		}},
+		{"StatefulSet", func() (runtime.Object, error) {
+			return clientset.AppsV1().StatefulSets(namespace).List(ctx, opts)
+		}},
+		{"DaemonSet", func() (runtime.Object, error) {
+			return clientset.AppsV1().DaemonSets(namespace).List(ctx, opts)
+		}},
+		{"Job", func() (runtime.Object, error) {
+			return clientset.BatchV1().Jobs(namespace).List(ctx, opts)
+		}},
+		{"CronJob", func() (runtime.Object, error) {
+			return clientset.BatchV1().CronJobs(namespace).List(ctx, opts)
+		}},
+		{"ServiceAccount", func() (runtime.Object, error) {
+			return clientset.CoreV1().ServiceAccounts(namespace).List(ctx, opts)
+		}},
+		{"Role", func() (runtime.Object, error) {
+			return clientset.RbacV1().Roles(namespace).List(ctx, opts)
+		}},
+		{"RoleBinding", func() (runtime.Object, error) {
+			return clientset.RbacV1().RoleBindings(namespace).List(ctx, opts)
+		}},
+		{"NetworkPolicy", func() (runtime.Object, error) {
+			return clientset.NetworkingV1().NetworkPolicies(namespace).List(ctx, opts)
+		}},
+	}
+
+	uncopied := []string{}
+
+	for _, l := range lists {
+		list, err := l.list()
+		if err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			obj, err := meta.Accessor(item)
+			if err != nil {
+				return nil, err
+			}
+			if obj.GetLabels()[kube.ManagedByLabel] == kube.ManagedBy || len(obj.GetOwnerReferences()) > 0 {
+				continue
+			}
+			// Created in every namespace by the cluster
+			if l.kind == "ServiceAccount" && obj.GetName() == "default" {
+				continue
+			}
+			uncopied = append(uncopied, l.kind+" "+obj.GetName())
+		}
+	}
+
+	return uncopied, nil
+}
+
+// Spec returns the settings recorded on an environment. Environments
+// provisioned before settings were recorded are read from their namespace
+// labels and quota.
+func Spec(clientset *kubernetes.Clientset, namespace string) (spec.Environment, error) {
+
+	ctx := context.Background()
+
+	ns, err := clientset.CoreV1().
+		Namespaces().
+		Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return spec.Environment{}, err
+	}
+
+	if ns.Labels[namespacepkg.ManagedLabel] != "true" {
+		return spec.Environment{}, fmt.Errorf("namespace %s is %w", namespace, ErrNotManaged)
+	}
+
+	env, ok, err := spec.Recorded(ns)
+	if err != nil {
+		return spec.Environment{}, err
+	}
+	if ok {
+		// Settings recorded before storage could be changed
+		if env.Storage == "" {
+			env.Storage = quota.DefaultStorage
+		}
+		return env, nil
+	}
+
+	// Environments provisioned before settings were recorded
+	env = spec.Environment{
+		Owner:       ns.Labels[namespacepkg.OwnerLabel],
+		Env:         namespacepkg.Env(ns),
+		Team:        ns.Labels[namespacepkg.TeamLabel],
+		PodSecurity: ns.Labels[namespacepkg.PodSecurityLabel],
+		ExpiresAt:   ns.Annotations[namespacepkg.ExpiresAnnotation],
+	}
+	if env.Env == namespacepkg.DefaultEnv {
+		env.Env = ""
+	}
+
+	q, err := clientset.CoreV1().
+		ResourceQuotas(namespace).
+		Get(ctx, quota.QuotaName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return spec.Environment{}, fmt.Errorf("namespace %s has no %s; run \"podcraft migrate\" first: %w", namespace, quota.QuotaName, err)
+	}
+	if err != nil {
+		return spec.Environment{}, err
+	}
+
+	cpu := q.Spec.Hard[corev1.ResourceLimitsCPU]
+	memory := q.Spec.Hard[corev1.ResourceLimitsMemory]
+	pods := q.Spec.Hard[corev1.ResourcePods]
+
+	env.CPU = cpu.String()
+	env.Memory = memory.String()
+	env.Storage = quota.DefaultStorage
+	if storage, ok := q.Spec.Hard[corev1.ResourceRequestsStorage]; ok {
+		env.Storage = storage.String()
+	}
+	env.MaxPods = int(pods.Value())
+
+	return env, nil
+}
+
+// add serializes obj unless it is managed by PodCraft or owned by another
+// object.
+func (b *Bundle) add(obj interface {
+	metav1.Object
+	runtime.Object
+}, gvk schema.GroupVersionKind) error {
+
+	if obj.GetLabels()[kube.ManagedByLabel] == kube.ManagedBy || len(obj.GetOwnerReferences()) > 0 {
+		return nil
+	}
+
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	obj.SetNamespace("")
+	obj.SetUID("")
+	obj.SetResourceVersion("")
+	obj.SetGeneration(0)
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetManagedFields(nil)
+
+	annotations := obj.GetAnnotations()
+	for _, key := range clusterAnnotations {
+		delete(annotations, key)
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	b.Objects = append(b.Objects, Object{
+		Kind: gvk.Kind,
+		Name: obj.GetName(),
+		Data: data,
+	})
+
+	return nil
+}
+
+// FileName returns a default bundle file name for a namespace, stamped with
+// the current time.
+func FileName(namespace string) string {
+	return strings.TrimPrefix(namespace, "dev-") + "-" + time.Now().Format("20060102-150405") + ".tar.gz"
+}
+
+// Write stores the bundle as a gzipped tarball: the spec in podcraft.yaml
+// and each object in <kind>/<name>.yaml. An existing file is not
+// overwritten, and a partially written file is removed.
+func Write(fileName string, b *Bundle) error {
+
+	if slices.ContainsFunc(b.Objects, func(obj Object) bool { return obj.Kind == "Secret" }) {
+		return fmt.Errorf("bundle holds Secrets, which are not written to unencrypted files")
+	}
+
+	manifest, err := yaml.Marshal(Manifest{
+		APIVersion:  APIVersion,
+		Kind:        Kind,
+		Environment: b.Spec,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Bundles hold ConfigMaps and workload definitions
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = writeArchive(f, manifest, b.Objects)
+	if err != nil {
+		os.Remove(fileName)
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		os.Remove(fileName)
+	}
+
+	return err
+}
+
+func writeArchive(w io.Writer, manifest []byte, objects []Object) error {
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := writeEntry(tw, SpecFile, manifest)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		err = writeEntry(tw, path.Join(strings.ToLower(obj.Kind), obj.Name+".yaml"), obj.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	return err
+}
+
+// Read loads a bundle written by Write.
+func Read(fileName string) (*Bundle, error) {
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a PodCraft bundle: %w", fileName, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	b := &Bundle{}
+	found := false
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		if header.Name == SpecFile {
+			var manifest Manifest
+			err = yaml.UnmarshalStrict(data, &manifest)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", SpecFile, err)
+			}
+			if manifest.APIVersion != APIVersion || manifest.Kind != Kind {
+				return nil, fmt.Errorf("%s: unsupported %s/%s", SpecFile, manifest.APIVersion, manifest.Kind)
+			}
+			b.Spec = manifest.Environment
+			found = true
+			continue
+		}
+
+		dir, file := path.Split(header.Name)
+		i := slices.IndexFunc(Kinds, func(kind string) bool {
+			return strings.ToLower(kind)+"/" == dir
+		})
+		if i < 0 || !strings.HasSuffix(file, ".yaml") {
+			return nil, fmt.Errorf("unexpected file %s in bundle", header.Name)
+		}
+
+		b.Objects = append(b.Objects, Object{
+			Kind: Kinds[i],
+			Name: strings.TrimSuffix(file, ".yaml"),
+			Data: data,
+		})
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%s has no %s", fileName, SpecFile)
+	}
+
+	return b, nil
+}
+
+// Restore creates the objects of the bundle in namespace, in the order of
+// Kinds. References to services in the namespace the bundle was exported
+// from are rewritten to namespace. Objects that already exist are left
+// alone; failures are reported after all objects have been tried.
+func (b *Bundle) Restore(clientset *kubernetes.Clientset, namespace string) error {
+
+	ctx := context.Background()
+
+	errs := []error{}
+
+	for _, kind := range Kinds {
+		for _, obj := range b.Objects {
+			if obj.Kind != kind {
+				continue
+			}
+
+			data := rewriteNamespace(obj.Data, b.Spec.Namespace(), namespace)
+
+			err := create(ctx, clientset, namespace, kind, data)
+			switch {
+			case apierrors.IsAlreadyExists(err):
+				fmt.Println(kind, obj.Name, "already exists")
+			case err != nil:
+				fmt.Println(kind, obj.Name, "failed:", err)
+				errs = append(errs, fmt.Errorf("%s %s: %w", kind, obj.Name, err))
+			default:
+				fmt.Println(kind, obj.Name, "restored")
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// rewriteNamespace rewrites references to services in namespace source,
+// <service>.<source> as well as <service>.<source>.svc[.cluster.local], to
+// namespace target. Longer names starting with source, such as dev-aman-x
+// for dev-aman, are left alone.
+func rewriteNamespace(data []byte, source, target string) []byte {
+
+	if source == target {
+		return data
+	}
+
+	re := regexp.MustCompile(`\.` + regexp.QuoteMeta(source) + `([^a-z0-9-]|$)`)
+
+	return re.ReplaceAll(data, []byte("."+target+"${1}"))
+}
+
+// create decodes one object of the given kind and creates it in namespace.
+func create(ctx context.Context, clientset *kubernetes.Clientset, namespace, kind string, data []byte) error {
+
+	opts := metav1.CreateOptions{}
+
+	switch kind {
+	case "Secret":
+		var obj corev1.Secret
+		if err := yaml.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		obj.Namespace = namespace
+		_, err := clientset.CoreV1().Secrets(namespace).Create(ctx, &obj, opts)
+		return err
+	case "ConfigMap":
+		var obj corev1.ConfigMap
+		if err := yaml.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		obj.Namespace = namespace
+		_, err := clientset.CoreV1().ConfigMaps(namespace).Create(ctx, &obj, opts)
+		return err
+	case "PersistentVolumeClaim":
+		var obj corev1.PersistentVolumeClaim
+		if err := yaml.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		obj.Namespace = namespace
+		_, err := clientset.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, &obj, opts)
+		return err
+	case "Service":
+		var obj corev1.Service
+		if err := yaml.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		obj.Namespace = namespace
+		_, err := clientset.CoreV1().Services(namespace).Create(ctx, &obj, opts)
+		return err
+	case "Deployment":
+		var obj appsv1.Deployment
+		if err := yaml.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		obj.Namespace = namespace
+		_, err := clientset.AppsV1().Deployments(namespace).Create(ctx, &obj, opts)
+		return err
+	case "Ingress":
+		var obj networkingv1.Ingress
+		if err := yaml.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		obj.Namespace = namespace
+		_, err := clientset.NetworkingV1().Ingresses(namespace).Create(ctx, &obj, opts)
+		return err
+	}
+
+	return fmt.Errorf("unsupported kind %s", kind)
+}
@@ -0,0 +1,158 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sarthakK31/podcraft/pkg/spec"
+)
+
+func TestWriteRead(t *testing.T) {
+
+	b := &Bundle{
+		Spec: spec.Environment{Owner: "aman", Env: "feature-x", Team: "payments", CPU: "2", Memory: "2Gi", MaxPods: 10, Storage: "5Gi"},
+		Objects: []Object{
+			{Kind: "ConfigMap", Name: "settings", Data: []byte("apiVersion: v1\nkind: ConfigMap\n")},
+			{Kind: "Deployment", Name: "api", Data: []byte("apiVersion: apps/v1\nkind: Deployment\n")},
+		},
+	}
+
+	fileName := filepath.Join(t.TempDir(), "aman.tar.gz")
+
+	if err := Write(fileName, b); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	got, err := Read(fileName)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, b) {
+		t.Errorf("Read() = %+v, want %+v", got, b)
+	}
+
+	if err := Write(fileName, b); err == nil {
+		t.Errorf("Write() overwrote an existing bundle")
+	}
+}
+
+func TestWriteRefusesSecrets(t *testing.T) {
+
+	b := &Bundle{
+		Spec:    spec.Environment{Owner: "aman", CPU: "2", Memory: "2Gi", MaxPods: 10},
+		Objects: []Object{{Kind: "Secret", Name: "token", Data: []byte("kind: Secret\n")}},
+	}
+
+	fileName := filepath.Join(t.TempDir(), "aman.tar.gz")
+
+	err := Write(fileName, b)
+	if err == nil || !strings.Contains(err.Error(), "Secrets") {
+		t.Fatalf("Write() error = %v, want a refusal of Secrets", err)
+	}
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Errorf("Write() left %s behind", fileName)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+
+	manifest := "apiVersion: " + APIVersion + "\nkind: " + Kind + "\nowner: aman\ncpu: \"2\"\nmemory: 2Gi\nmaxPods: 10\n"
+
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"no spec", map[string]string{"configmap/settings.yaml": "kind: ConfigMap\n"}, "has no " + SpecFile},
+		{"unknown kind", map[string]string{SpecFile: manifest, "pod/api.yaml": "kind: Pod\n"}, "unexpected file pod/api.yaml"},
+		{"not yaml", map[string]string{SpecFile: manifest, "configmap/settings.json": "{}"}, "unexpected file"},
+		{"unsupported version", map[string]string{SpecFile: "apiVersion: podcraft.dev/v2\nkind: Environment\n"}, "unsupported podcraft.dev/v2/Environment"},
+		{"unknown spec field", map[string]string{SpecFile: manifest + "replicas: 3\n"}, "unknown field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "bundle.tar.gz")
+			writeTestArchive(t, fileName, tt.files)
+
+			_, err := Read(fileName)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Read() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("not gzip", func(t *testing.T) {
+		fileName := filepath.Join(t.TempDir(), "bundle.tar.gz")
+		if err := os.WriteFile(fileName, []byte("plain text"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Read(fileName)
+		if err == nil || !strings.Contains(err.Error(), "is not a PodCraft bundle") {
+			t.Errorf("Read() error = %v", err)
+		}
+	})
+}
+
+func writeTestArchive(t *testing.T, fileName string, files map[string]string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		if err := writeEntry(tw, name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(fileName, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRewriteNamespace(t *testing.T) {
+
+	tests := []struct {
+		name, source, target, data, want string
+	}{
+		{"short form", "dev-aman", "dev-dana", "url: http://api.dev-aman:8080", "url: http://api.dev-dana:8080"},
+		{"svc form", "dev-aman", "dev-dana", "host: api.dev-aman.svc", "host: api.dev-dana.svc"},
+		{"fully qualified", "dev-aman", "dev-dana", "api.dev-aman.svc.cluster.local", "api.dev-dana.svc.cluster.local"},
+		{"end of line", "dev-aman", "dev-dana", "host: api.dev-aman\nport: 80", "host: api.dev-dana\nport: 80"},
+		{"end of data", "dev-aman", "dev-dana", "api.dev-aman", "api.dev-dana"},
+		{"several", "dev-aman", "dev-dana", "a.dev-aman,b.dev-aman/", "a.dev-dana,b.dev-dana/"},
+		{"longer namespace", "dev-aman", "dev-dana", "api.dev-aman-x:80", "api.dev-aman-x:80"},
+		{"no leading dot", "dev-aman", "dev-dana", "namespace: dev-aman", "namespace: dev-aman"},
+		{"same namespace", "dev-aman", "dev-aman", "api.dev-aman:80", "api.dev-aman:80"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(rewriteNamespace([]byte(tt.data), tt.source, tt.target)); got != tt.want {
+				t.Errorf("rewriteNamespace(%q) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
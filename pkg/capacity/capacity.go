@@ -0,0 +1,112 @@
+package capacity
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+)
+
+// nodeResources maps quota resources to the node allocatable they draw on.
+var nodeResources = map[corev1.ResourceName]corev1.ResourceName{
+	corev1.ResourceLimitsCPU:    corev1.ResourceCPU,
+	corev1.ResourceLimitsMemory: corev1.ResourceMemory,
+	corev1.ResourcePods:         corev1.ResourcePods,
+}
+
+// Order is the order resources are reported in.
+var Order = []corev1.ResourceName{
+	corev1.ResourceLimitsCPU,
+	corev1.ResourceLimitsMemory,
+	corev1.ResourcePods,
+}
+
+// Usage is the quota allocated across PodCraft namespaces for one resource
+// compared to the allocatable capacity of the selected nodes.
+type Usage struct {
+	Resource    corev1.ResourceName
+	Allocated   float64
+	Allocatable float64
+	Ratio       float64
+}
+
+// Overcommit sums the dev-quota hard limits of all PodCraft-managed
+// namespaces, with the limits of the namespaces being admitted replaced by
+// their hard limits in admit, and compares them to the allocatable capacity
+// of the nodes matching nodeSelector (all nodes if empty).
+func Overcommit(clientset *kubernetes.Clientset, admit map[string]corev1.ResourceList, nodeSelector string) ([]Usage, error) {
+
+	allocation, err := quota.HardByNamespace(clientset, namespacepkg.ManagedLabel+"=true")
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(allocation, admit)
+
+	nodes, err := clientset.CoreV1().
+		Nodes().
+		List(context.Background(), metav1.ListOptions{
+			LabelSelector: nodeSelector,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodes.Items) == 0 {
+		return nil, fmt.Errorf("no nodes match selector %q", nodeSelector)
+	}
+
+	var usage []Usage
+
+	for _, name := range Order {
+		u := Usage{Resource: name}
+
+		for _, list := range allocation {
+			if q, ok := list[name]; ok {
+				u.Allocated += q.AsApproximateFloat64()
+			}
+		}
+
+		for _, node := range nodes.Items {
+			if q, ok := node.Status.Allocatable[nodeResources[name]]; ok {
+				u.Allocatable += q.AsApproximateFloat64()
+			}
+		}
+
+		if u.Allocatable > 0 {
+			u.Ratio = u.Allocated / u.Allocatable
+		}
+
+		usage = append(usage, u)
+	}
+
+	return usage, nil
+}
+
+// Check prints the overcommit ratio of every resource to r and returns an
+// error if any exceeds maxRatio.
+func Check(usage []Usage, maxRatio float64, r *kube.Reporter) error {
+
+	var exceeded []string
+
+	r.Println("Cluster overcommit:")
+	for _, u := range usage {
+		r.Printf("  %s: %.2fx\n", u.Resource, u.Ratio)
+		if u.Ratio > maxRatio {
+			exceeded = append(exceeded, fmt.Sprintf("%s %.2fx", u.Resource, u.Ratio))
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return fmt.Errorf("cluster overcommit exceeds %.2fx: %s", maxRatio, strings.Join(exceeded, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Cluster is a registered cluster PodCraft manages environments on.
+type Cluster struct {
+	Kubeconfig string `json:"kubeconfig"`
+	Context    string `json:"context,omitempty"`
+	// Defaults are flag values used on this cluster unless the flag is
+	// given, keyed by flag name (e.g. cpu, memory, max-pods).
+	Defaults map[string]string `json:"defaults,omitempty"`
+}
+
+// Registry maps cluster names to clusters.
+type Registry struct {
+	Clusters map[string]Cluster `json:"clusters"`
+}
+
+// DefaultPath returns the path of the registry: ~/.podcraft/clusters.yaml.
+func DefaultPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".podcraft", "clusters.yaml")
+}
+
+// Load reads the registry at path. A missing file is an empty registry.
+func Load(path string) (*Registry, error) {
+
+	r := &Registry{Clusters: map[string]Cluster{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.UnmarshalStrict(data, r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if r.Clusters == nil {
+		r.Clusters = map[string]Cluster{}
+	}
+
+	return r, nil
+}
+
+// Save writes the registry to path, creating its directory.
+func (r *Registry) Save(path string) error {
+
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+// Get returns the named cluster with its kubeconfig path expanded.
+func (r *Registry) Get(name string) (Cluster, error) {
+
+	c, ok := r.Clusters[name]
+	if !ok {
+		return Cluster{}, fmt.Errorf("cluster %s is not registered (podcraft cluster list)", name)
+	}
+
+	if rest, ok := strings.CutPrefix(c.Kubeconfig, "~/"); ok {
+		c.Kubeconfig = filepath.Join(os.Getenv("HOME"), rest)
+	}
+
+	return c, nil
+}
+
+// Names returns the names of the registered clusters, sorted.
+func (r *Registry) Names() []string {
+
+	names := make([]string, 0, len(r.Clusters))
+	for name := range r.Clusters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
@@ -0,0 +1,119 @@
+package kube
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FieldManager is the server-side apply field manager of every object
+// PodCraft reconciles.
+const FieldManager = "podcraft"
+
+// ForceConflicts makes applies take over fields currently owned by another
+// field manager instead of failing with a conflict.
+var ForceConflicts = true
+
+// Result is the outcome of reconciling one object.
+type Result string
+
+const (
+	Created   Result = "created"
+	Updated   Result = "updated"
+	Unchanged Result = "unchanged"
+	// Drifted means the object was changed outside PodCraft and restored;
+	// its recorded spec hash still matched the desired spec.
+	Drifted Result = "drifted"
+)
+
+// ApplyOptions returns the options for a server-side apply by PodCraft.
+func ApplyOptions(dryRun bool) metav1.ApplyOptions {
+
+	opts := metav1.ApplyOptions{
+		FieldManager: FieldManager,
+		Force:        ForceConflicts,
+	}
+
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	return opts
+}
+
+// Apply reconciles one object with server-side apply. An existing object is
+// first applied as a dry run; only if the fields returned by owned differ
+// between the live object and the dry-run result is the apply written. As
+// the dry run only changes fields PodCraft sets and is defaulted like the
+// live object, this reports drift of PodCraft's fields only. Labels and
+// annotations are always compared too, so objects created before PodCraft
+// labelled them are brought up to date. The object and result are passed to
+// r.
+func Apply[T metav1.Object](
+	r *Reporter,
+	get func(ctx context.Context) (T, error),
+	apply func(ctx context.Context, opts metav1.ApplyOptions) (T, error),
+	owned func(obj T) any,
+) (Result, error) {
+
+	ctx := context.Background()
+
+	var result Result
+	var obj T
+
+	// The whole get/compare/apply sequence is retried, so a retry after a
+	// transient failure compares against the latest live object.
+	err := Retry(func() error {
+
+		live, err := get(ctx)
+		if apierrors.IsNotFound(err) {
+			obj, err = apply(ctx, ApplyOptions(false))
+			if err != nil {
+				return err
+			}
+			result = Created
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		desired, err := apply(ctx, ApplyOptions(true))
+		if err != nil {
+			return err
+		}
+
+		if equality.Semantic.DeepEqual(metadata(live, owned), metadata(desired, owned)) {
+			obj = live
+			result = Unchanged
+			return nil
+		}
+
+		obj, err = apply(ctx, ApplyOptions(false))
+		if err != nil {
+			return err
+		}
+
+		hash := live.GetAnnotations()[SpecHashAnnotation]
+		if hash != "" && hash == desired.GetAnnotations()[SpecHashAnnotation] {
+			result = Drifted
+		} else {
+			result = Updated
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	r.observe(obj, result)
+
+	return result, nil
+}
+
+// metadata returns the fields of obj compared by Apply.
+func metadata[T metav1.Object](obj T, owned func(obj T) any) []any {
+	return []any{owned(obj), obj.GetLabels(), obj.GetAnnotations()}
+}
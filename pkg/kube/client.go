@@ -1,15 +1,42 @@
 package kube
 
 import (
+	"time"
+
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-func GetClient(kubeconfig string) (*kubernetes.Clientset, error) {
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+// Client settings, set from global flags.
+var (
+	// QPS and Burst rate-limit requests to the API server.
+	QPS   float32 = 20
+	Burst         = 40
+	// Timeout bounds every single API call; zero means no timeout.
+	Timeout = 30 * time.Second
+)
+
+// GetClient builds a clientset from the kubeconfig file. An empty context
+// name uses the file's current-context.
+func GetClient(kubeconfig string, contextName string) (*kubernetes.Clientset, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+
+	configOverrides := &clientcmd.ConfigOverrides{
+		CurrentContext: contextName,
+	}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		configOverrides,
+	).ClientConfig()
 	if err != nil {
 		return nil, err
 	}
 
+	config.QPS = QPS
+	config.Burst = Burst
+	config.Timeout = Timeout
+
 	return kubernetes.NewForConfig(config)
 }
@@ -0,0 +1,60 @@
+package kube
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+const (
+	// ManagedByLabel marks every object PodCraft creates, so PodCraft
+	// objects can be told apart from those of developers or other tools.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedBy      = "podcraft"
+
+	OwnerLabel = "podcraft.dev/owner"
+	TeamLabel  = "podcraft.dev/team"
+
+	// SpecHashAnnotation records a hash of the desired spec an object was
+	// last applied with.
+	SpecHashAnnotation = "podcraft.dev/spec-hash"
+)
+
+// Labels returns the labels of an object PodCraft creates for a developer
+// and/or a team. Empty owner or team are left out.
+func Labels(owner, team string) map[string]string {
+
+	labels := map[string]string{
+		ManagedByLabel: ManagedBy,
+	}
+	if owner != "" {
+		labels[OwnerLabel] = owner
+	}
+	if team != "" {
+		labels[TeamLabel] = team
+	}
+
+	return labels
+}
+
+// SpecHash returns a short, stable hash of the desired spec of an object.
+func SpecHash(spec any) string {
+
+	// Maps are marshalled with sorted keys, so equal specs hash equally
+	data, err := json.Marshal(spec)
+	if err != nil {
+		panic(err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// Annotations returns the annotations recording the desired spec of an
+// object.
+func Annotations(spec any) map[string]string {
+	return map[string]string{
+		SpecHashAnnotation: SpecHash(spec),
+	}
+}
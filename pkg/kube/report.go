@@ -0,0 +1,73 @@
+package kube
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reporter prints the progress of one run and receives the result of every
+// object Apply reconciles in it. A nil *Reporter prints to stdout and
+// observes nothing.
+type Reporter struct {
+	// Observe, if set, is called with every reconciled object and the
+	// result. Runs may be concurrent, so it must be safe for concurrent use.
+	Observe func(obj metav1.Object, result Result)
+
+	// Prefix, if set, starts every printed line, e.g. to tell apart the
+	// output of concurrent runs.
+	Prefix string
+}
+
+// stdout serializes writes of concurrent runs so lines are not torn.
+var stdout sync.Mutex
+
+// Println prints a line like fmt.Println.
+func (r *Reporter) Println(a ...any) {
+	r.write(fmt.Sprintln(a...))
+}
+
+// Printf prints like fmt.Printf.
+func (r *Reporter) Printf(format string, a ...any) {
+	r.write(fmt.Sprintf(format, a...))
+}
+
+func (r *Reporter) write(s string) {
+	if r != nil && r.Prefix != "" {
+		lines := strings.SplitAfter(s, "\n")
+		for i, line := range lines {
+			if line != "" && line != "\n" {
+				lines[i] = r.Prefix + line
+			}
+		}
+		s = strings.Join(lines, "")
+	}
+
+	stdout.Lock()
+	defer stdout.Unlock()
+
+	os.Stdout.WriteString(s)
+}
+
+func (r *Reporter) observe(obj metav1.Object, result Result) {
+	if r != nil && r.Observe != nil {
+		r.Observe(obj, result)
+	}
+}
+
+// Report prints the outcome of reconciling an object described by what.
+func (r *Reporter) Report(what string, result Result) {
+	switch result {
+	case Created:
+		r.Println(what, "created")
+	case Updated:
+		r.Println(what, "updated")
+	case Drifted:
+		r.Println(what, "drifted from desired state and was restored")
+	default:
+		r.Println(what, "already matches desired state")
+	}
+}
@@ -0,0 +1,47 @@
+package kube
+
+import (
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// Backoff is the retry schedule for conflicts and transient API errors.
+var Backoff = wait.Backoff{
+	Steps:    6,
+	Duration: 200 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+	Cap:      10 * time.Second,
+}
+
+// Retriable reports whether an API error is worth retrying: resourceVersion
+// conflicts, rate limiting and transient server-side failures. A
+// server-side apply conflict with another field manager is also a 409, but
+// is not retried, as only --force-conflicts resolves it.
+func Retriable(err error) bool {
+	return (apierrors.IsConflict(err) && !FieldManagerConflict(err)) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsUnexpectedServerError(err)
+}
+
+// FieldManagerConflict reports whether err is a server-side apply conflict
+// over fields owned by another field manager.
+func FieldManagerConflict(err error) bool {
+	_, ok := apierrors.StatusCause(err, metav1.CauseTypeFieldManagerConflict)
+	return ok
+}
+
+// Retry runs fn, retrying with exponential backoff while it fails with a
+// retriable error. fn must re-read any object it updates, so a conflict is
+// resolved against the latest version.
+func Retry(fn func() error) error {
+	return retry.OnError(Backoff, Retriable, fn)
+}
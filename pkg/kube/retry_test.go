@@ -0,0 +1,39 @@
+package kube
+
+import (
+	"errors"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRetriable(t *testing.T) {
+
+	resource := schema.GroupResource{Resource: "resourcequotas"}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"resourceVersion conflict", apierrors.NewConflict(resource, "dev-quota", errors.New("modified")), true},
+		{"field manager conflict", apierrors.NewApplyConflict([]metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldManagerConflict,
+			Message: `conflict with "kubectl"`,
+			Field:   ".spec.hard",
+		}}, "Apply failed with 1 conflict"), false},
+		{"rate limited", apierrors.NewTooManyRequests("slow down", 1), true},
+		{"not found", apierrors.NewNotFound(resource, "dev-quota"), false},
+		{"not an API error", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Retriable(tt.err); got != tt.want {
+				t.Errorf("Retriable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
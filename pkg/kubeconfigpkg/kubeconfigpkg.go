@@ -1,45 +1,130 @@
 package kubeconfigpkg
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
 	authv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
 )
 
-func Generate(clientset *kubernetes.Clientset, kubeconfigPath string, namespace string, username string) error {
+// Options controls where and how the developer kubeconfig is written.
+type Options struct {
+	// Dir is the directory the kubeconfig is written to.
+	Dir string
+	// Force overwrites an existing kubeconfig instead of refusing.
+	Force bool
+	// EncryptTo is an age recipient (age1..., ssh-ed25519, ssh-rsa) or a
+	// path to a file of recipients. When set, the kubeconfig is written
+	// ASCII-armored and encrypted instead of in plaintext.
+	EncryptTo string
+	// Cluster is the name of the registered cluster the kubeconfig is for,
+	// if any. It is part of the file name, so a developer can have
+	// environments of the same name on several clusters.
+	Cluster string
+}
 
-	ctx := context.Background()
+// Path returns the file the kubeconfig for namespace is written to:
+// aman.kubeconfig for dev-aman, aman-feature-x.kubeconfig for
+// dev-aman-feature-x, and aman@staging.kubeconfig for dev-aman on the
+// registered cluster staging.
+func (o Options) Path(namespace string) string {
+	fileName := strings.TrimPrefix(namespace, "dev-")
+	if o.Cluster != "" {
+		fileName += "@" + o.Cluster
+	}
+	fileName += ".kubeconfig"
+	if o.EncryptTo != "" {
+		fileName += ".age"
+	}
+	return filepath.Join(o.Dir, fileName)
+}
+
+// Generate issues a token for the developer's ServiceAccount (username in
+// identityNamespace) and writes a standalone kubeconfig for namespace
+// according to opts, printing where it was written to r.
+func Generate(clientset *kubernetes.Clientset, kubeconfigPath string, contextName string, identityNamespace string, namespace string, username string, opts Options, r *kube.Reporter) error {
+
+	fileName := opts.Path(namespace)
+
+	// Refuse early so no token is issued for a file we will not write
+	if !opts.Force {
+		if _, err := os.Stat(fileName); err == nil {
+			return fmt.Errorf("kubeconfig %s already exists (use --force to overwrite)", fileName)
+		}
+	}
+
+	var recipients []age.Recipient
+	if opts.EncryptTo != "" {
+		var err error
+		recipients, err = parseRecipients(opts.EncryptTo)
+		if err != nil {
+			return err
+		}
+	}
+
+	devConfig, err := Build(clientset, kubeconfigPath, contextName, identityNamespace, namespace, username, r)
+	if err != nil {
+		return err
+	}
+
+	content, err := clientcmd.Write(*devConfig)
+	if err != nil {
+		return err
+	}
 
 	// -------------------------
-	// 1. Generate Token
+	// Encrypt (optional)
 	// -------------------------
 
-	tokenRequest := &authv1.TokenRequest{
-		Spec: authv1.TokenRequestSpec{
-			ExpirationSeconds: func() *int64 {
-				t := int64(3600 * 24) // 24 hours
-				return &t
-			}(),
-		},
+	if recipients != nil {
+		content, err = encrypt(content, recipients)
+		if err != nil {
+			return err
+		}
 	}
 
-	tokenResponse, err := clientset.CoreV1().
-		ServiceAccounts(namespace).
-		CreateToken(ctx, username, tokenRequest, metav1.CreateOptions{})
+	// -------------------------
+	// Write File (0600)
+	// -------------------------
+
+	err = writeFile(fileName, content, opts.Force)
 	if err != nil {
 		return err
 	}
 
-	token := tokenResponse.Status.Token
-	fmt.Println("ServiceAccount token generated")
+	if recipients != nil {
+		r.Println("Encrypted kubeconfig written to:", fileName)
+	} else {
+		r.Println("Kubeconfig written to:", fileName)
+	}
+
+	return nil
+}
+
+// Build issues a token for the developer's ServiceAccount (username in
+// identityNamespace) and returns a kubeconfig whose context defaults to
+// namespace, using the cluster of the given admin context. Progress is
+// printed to r.
+func Build(clientset *kubernetes.Clientset, kubeconfigPath string, contextName string, identityNamespace string, namespace string, username string, r *kube.Reporter) (*api.Config, error) {
+
+	ctx := context.Background()
 
 	// -------------------------
-	// 2. Load Admin Kubeconfig
+	// 1. Load Admin Kubeconfig
 	// -------------------------
 
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
@@ -53,47 +138,263 @@ func Generate(clientset *kubernetes.Clientset, kubeconfigPath string, namespace
 
 	rawConfig, err := adminConfig.RawConfig()
 	if err != nil {
+		return nil, err
+	}
+
+	if contextName == "" {
+		contextName = rawConfig.CurrentContext
+	}
+	if contextName == "" {
+		return nil, fmt.Errorf("no current-context set in %s (use --context)", kubeconfigPath)
+	}
+
+	adminContext, ok := rawConfig.Contexts[contextName]
+	if !ok {
+		return nil, fmt.Errorf("context %q not found in %s", contextName, kubeconfigPath)
+	}
+
+	clusterName := adminContext.Cluster
+	cluster, ok := rawConfig.Clusters[clusterName]
+	if !ok {
+		return nil, fmt.Errorf("cluster %q referenced by context %q not found in %s", clusterName, contextName, kubeconfigPath)
+	}
+
+	devCluster, err := copyCluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	// -------------------------
+	// 2. Generate Token
+	// -------------------------
+
+	tokenRequest := &authv1.TokenRequest{
+		Spec: authv1.TokenRequestSpec{
+			ExpirationSeconds: func() *int64 {
+				t := int64(3600 * 24) // 24 hours
+				return &t
+			}(),
+		},
+	}
+
+	var tokenResponse *authv1.TokenRequest
+	err = kube.Retry(func() error {
+		var err error
+		tokenResponse, err = clientset.CoreV1().
+			ServiceAccounts(identityNamespace).
+			CreateToken(ctx, username, tokenRequest, metav1.CreateOptions{})
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	currentContext := rawConfig.CurrentContext
-	clusterName := rawConfig.Contexts[currentContext].Cluster
-	cluster := rawConfig.Clusters[clusterName]
+	token := tokenResponse.Status.Token
+	r.Println("ServiceAccount token generated")
 
 	// -------------------------
 	// 3. Build Dev Kubeconfig
 	// -------------------------
 
+	// Names are derived from the real cluster so several PodCraft
+	// kubeconfigs can be merged without colliding.
+	userName := username + "@" + clusterName
+	devContextName := namespace + "@" + clusterName
+
 	devConfig := api.Config{
 		Clusters: map[string]*api.Cluster{
-			"cluster": {
-				Server:                   cluster.Server,
-				CertificateAuthorityData: cluster.CertificateAuthorityData,
-			},
+			clusterName: devCluster,
 		},
 		Contexts: map[string]*api.Context{
-			"dev-context": {
-				Cluster:   "cluster",
-				AuthInfo:  "dev-user",
+			devContextName: {
+				Cluster:   clusterName,
+				AuthInfo:  userName,
 				Namespace: namespace,
 			},
 		},
 		AuthInfos: map[string]*api.AuthInfo{
-			"dev-user": {
+			userName: {
 				Token: token,
 			},
 		},
-		CurrentContext: "dev-context",
+		CurrentContext: devContextName,
+	}
+
+	return &devConfig, nil
+}
+
+// copyCluster copies every connection setting of the admin cluster entry. A
+// CA file referenced by path is inlined so the kubeconfig is self-contained.
+func copyCluster(cluster *api.Cluster) (*api.Cluster, error) {
+
+	devCluster := &api.Cluster{
+		Server:                   cluster.Server,
+		TLSServerName:            cluster.TLSServerName,
+		InsecureSkipTLSVerify:    cluster.InsecureSkipTLSVerify,
+		CertificateAuthorityData: cluster.CertificateAuthorityData,
+		ProxyURL:                 cluster.ProxyURL,
+		DisableCompression:       cluster.DisableCompression,
+	}
+
+	if len(devCluster.CertificateAuthorityData) == 0 && cluster.CertificateAuthority != "" {
+		caData, err := os.ReadFile(cluster.CertificateAuthority)
+		if err != nil {
+			return nil, fmt.Errorf("reading cluster CA file: %w", err)
+		}
+		devCluster.CertificateAuthorityData = caData
+	}
+
+	return devCluster, nil
+}
+
+// Merge inserts or updates the entries of devConfig in the kubeconfig at
+// path, leaving every other entry untouched. The file is created if missing.
+// With switchContext the merged context becomes the current-context.
+func Merge(devConfig *api.Config, path string, switchContext bool) error {
+
+	config := api.NewConfig()
+
+	if _, err := os.Stat(path); err == nil {
+		config, err = clientcmd.LoadFromFile(path)
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// A same-named cluster pointing elsewhere belongs to someone else
+	for name, cluster := range devConfig.Clusters {
+		if existing, ok := config.Clusters[name]; ok && existing.Server != cluster.Server {
+			return fmt.Errorf("cluster %q in %s points to %s, not %s; refusing to overwrite it", name, path, existing.Server, cluster.Server)
+		}
+	}
+
+	for name, cluster := range devConfig.Clusters {
+		config.Clusters[name] = cluster
+	}
+	for name, authInfo := range devConfig.AuthInfos {
+		config.AuthInfos[name] = authInfo
+	}
+	for name, kubeContext := range devConfig.Contexts {
+		config.Contexts[name] = kubeContext
+	}
+
+	if switchContext || config.CurrentContext == "" {
+		config.CurrentContext = devConfig.CurrentContext
 	}
 
-	fileName := username + ".kubeconfig"
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
 
-	err = clientcmd.WriteToFile(devConfig, fileName)
+	// WriteToFile writes with 0600
+	err := clientcmd.WriteToFile(*config, path)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Kubeconfig written to:", fileName)
+	fmt.Printf("Context %s merged into: %s\n", devConfig.CurrentContext, path)
 
 	return nil
 }
+
+// writeFile writes content readable by the owner only. Without force an
+// existing file is never replaced, even if it appeared after the early check.
+func writeFile(fileName string, content []byte, force bool) error {
+
+	if dir := filepath.Dir(fileName); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(fileName, flags, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("kubeconfig %s already exists (use --force to overwrite)", fileName)
+		}
+		return err
+	}
+
+	// An overwritten file keeps its old mode, so tighten it explicitly
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// parseRecipients accepts either a single recipient or a file with one
+// recipient per line (blank lines and # comments are ignored).
+func parseRecipients(value string) ([]age.Recipient, error) {
+
+	lines := []string{value}
+
+	if data, err := os.ReadFile(value); err == nil {
+		lines = strings.Split(string(data), "\n")
+	}
+
+	var recipients []age.Recipient
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var r age.Recipient
+		var err error
+		if strings.HasPrefix(line, "ssh-") {
+			r, err = agessh.ParseRecipient(line)
+		} else {
+			r, err = age.ParseX25519Recipient(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid --encrypt-to recipient %q: %w", line, err)
+		}
+		recipients = append(recipients, r)
+	}
+
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipients found in %s", value)
+	}
+
+	return recipients, nil
+}
+
+func encrypt(content []byte, recipients []age.Recipient) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	armored := armor.NewWriter(&buf)
+
+	w, err := age.Encrypt(armored, recipients...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.Copy(w, bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := armored.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,127 @@
+package kubeconfigpkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestOptionsPath(t *testing.T) {
+
+	tests := []struct {
+		opts      Options
+		namespace string
+		want      string
+	}{
+		{Options{}, "dev-aman", "aman.kubeconfig"},
+		{Options{Dir: "out"}, "dev-aman-feature-x", filepath.Join("out", "aman-feature-x.kubeconfig")},
+		{Options{Cluster: "staging"}, "dev-aman", "aman@staging.kubeconfig"},
+		{Options{EncryptTo: "age1example"}, "dev-aman", "aman.kubeconfig.age"},
+		{Options{Dir: "out", Cluster: "staging", EncryptTo: "age1example"}, "dev-aman", filepath.Join("out", "aman@staging.kubeconfig.age")},
+	}
+
+	for _, tt := range tests {
+		if got := tt.opts.Path(tt.namespace); got != tt.want {
+			t.Errorf("%+v.Path(%q) = %q, want %q", tt.opts, tt.namespace, got, tt.want)
+		}
+	}
+}
+
+// devConfig returns a kubeconfig like Build does for namespace on a cluster
+// at server.
+func devConfig(name, server string) *api.Config {
+
+	config := api.NewConfig()
+	config.Clusters["podcraft"] = &api.Cluster{Server: server}
+	config.AuthInfos[name] = &api.AuthInfo{Token: "token-" + name}
+	config.Contexts[name] = &api.Context{Cluster: "podcraft", AuthInfo: name, Namespace: name}
+	config.CurrentContext = name
+
+	return config
+}
+
+func TestMerge(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		existing      *api.Config
+		switchContext bool
+		wantCurrent   string
+		wantContexts  []string
+		wantErr       string
+	}{
+		{
+			name:         "new file",
+			wantCurrent:  "dev-aman",
+			wantContexts: []string{"dev-aman"},
+		},
+		{
+			name:         "keeps other entries and current context",
+			existing:     devConfig("dev-dana", "https://cluster.example"),
+			wantCurrent:  "dev-dana",
+			wantContexts: []string{"dev-aman", "dev-dana"},
+		},
+		{
+			name:          "switches context",
+			existing:      devConfig("dev-dana", "https://cluster.example"),
+			switchContext: true,
+			wantCurrent:   "dev-aman",
+			wantContexts:  []string{"dev-aman", "dev-dana"},
+		},
+		{
+			name:     "refuses a cluster pointing elsewhere",
+			existing: devConfig("dev-dana", "https://other.example"),
+			wantErr:  "refusing to overwrite",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "nested", "config")
+			if tt.existing != nil {
+				if err := clientcmd.WriteToFile(*tt.existing, path); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := Merge(devConfig("dev-aman", "https://cluster.example"), path, tt.switchContext)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Merge() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Merge() error = %v", err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+			}
+
+			merged, err := clientcmd.LoadFromFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if merged.CurrentContext != tt.wantCurrent {
+				t.Errorf("current-context = %q, want %q", merged.CurrentContext, tt.wantCurrent)
+			}
+			for _, name := range tt.wantContexts {
+				if _, ok := merged.Contexts[name]; !ok {
+					t.Errorf("context %q missing", name)
+				}
+			}
+			if len(merged.Contexts) != len(tt.wantContexts) {
+				t.Errorf("got %d contexts, want %d", len(merged.Contexts), len(tt.wantContexts))
+			}
+		})
+	}
+}
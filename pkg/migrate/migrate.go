@@ -0,0 +1,373 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/network"
+	"github.com/sarthakK31/podcraft/pkg/quota"
+	"github.com/sarthakK31/podcraft/pkg/rbac"
+)
+
+// Names of objects of the legacy kind/dev-pods layout.
+const (
+	LegacyDenyPolicy   = "deny-all"
+	LegacySharedPolicy = "allow-shared"
+	LegacyLimitRange   = "dev-limits"
+
+	// legacyNamespaceLabel is the label legacy policies select namespaces by.
+	legacyNamespaceLabel = "name"
+)
+
+// Step is one change of a migration.
+type Step struct {
+	Description string
+	Run         func() error
+}
+
+// Plan is the migration of one environment to the current managed objects.
+// New objects are always in place before the legacy objects they replace are
+// deleted, so running pods keep their network access and quota throughout.
+type Plan struct {
+	Namespace string
+	Steps     []Step
+}
+
+// Quota is the quota of an environment, taken from its legacy ResourceQuota.
+type Quota struct {
+	CPU     string
+	Memory  string
+	Storage string
+	MaxPods int
+}
+
+// Detect inspects a developer environment for known legacy layouts and
+// returns the plan to convert it. The plan has no steps if the environment
+// is already current. q is the quota applied when the environment has no
+// ResourceQuota to take it from.
+func Detect(clientset *kubernetes.Clientset, namespace, owner, env string, q Quota) (*Plan, error) {
+
+	ctx := context.Background()
+
+	p := &Plan{Namespace: namespace}
+
+	ns, err := clientset.CoreV1().
+		Namespaces().
+		Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if current := ns.Labels[namespacepkg.OwnerLabel]; current != "" && current != owner {
+		return nil, fmt.Errorf("namespace %s belongs to %s", namespace, current)
+	}
+
+	// -------------------------
+	// Namespace labels
+	// -------------------------
+
+	if ns.Labels[namespacepkg.ManagedLabel] != "true" ||
+		ns.Labels[namespacepkg.OwnerLabel] == "" ||
+		ns.Labels[kube.ManagedByLabel] != kube.ManagedBy {
+		p.add(fmt.Sprintf("Namespace %s: add PodCraft labels (owner %s)", namespace, owner), func() error {
+			_, err := namespacepkg.EnsureNamespace(clientset, namespace, owner, env, "", "", nil)
+			return err
+		})
+	}
+
+	// -------------------------
+	// NetworkPolicies
+	// -------------------------
+
+	policies := clientset.NetworkingV1().NetworkPolicies(namespace)
+
+	legacyPolicies := []string{}
+	sharedNamespaces := []string{}
+
+	for _, name := range []string{LegacyDenyPolicy, LegacySharedPolicy} {
+		np, err := policies.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		legacyPolicies = append(legacyPolicies, name)
+
+		// Namespaces legacy policies admit ingress from by name
+		for _, rule := range np.Spec.Ingress {
+			for _, peer := range rule.From {
+				if peer.NamespaceSelector == nil {
+					continue
+				}
+				if from := peer.NamespaceSelector.MatchLabels[legacyNamespaceLabel]; from != "" && !slices.Contains(sharedNamespaces, from) {
+					sharedNamespaces = append(sharedNamespaces, from)
+				}
+			}
+		}
+	}
+
+	for _, name := range sharedNamespaces {
+		shared, err := clientset.CoreV1().
+			Namespaces().
+			Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if shared.Labels[network.SharedLabel] == "true" {
+			continue
+		}
+		p.add(fmt.Sprintf("Namespace %s: label %s=true (selected by name in a legacy policy)", name, network.SharedLabel), func() error {
+			return namespacepkg.Update(clientset, name, func(ns *corev1.Namespace) {
+				ns.Labels[network.SharedLabel] = "true"
+			})
+		})
+	}
+
+	current, err := unlabelled(ctx, clientset, namespace, network.PolicyNames)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(legacyPolicies) > 0 || current {
+		p.add("NetworkPolicies: apply "+strings.Join(network.PolicyNames, ", "), func() error {
+			return network.EnsureNetwork(clientset, namespace, kube.Labels(owner, ""), nil)
+		})
+	}
+
+	for _, name := range legacyPolicies {
+		p.add("NetworkPolicy "+name+": delete (replaced)", func() error {
+			return remove(func(ctx context.Context) error {
+				return policies.Delete(ctx, name, metav1.DeleteOptions{})
+			})
+		})
+	}
+
+	// -------------------------
+	// ResourceQuota and LimitRange
+	// -------------------------
+
+	quotas := clientset.CoreV1().ResourceQuotas(namespace)
+
+	legacyQuota, err := quotas.Get(ctx, quota.QuotaName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	convert := false
+
+	if err == nil {
+		q = fromHard(legacyQuota.Spec.Hard, q)
+
+		hard, err := quota.Hard(q.CPU, q.Memory, q.Storage, q.MaxPods)
+		if err != nil {
+			return nil, err
+		}
+
+		for name := range legacyQuota.Spec.Hard {
+			if _, ok := hard[name]; !ok {
+				convert = true
+			}
+		}
+
+		// Keys the current quota does not have are owned by another field
+		// manager and would survive server-side apply, so they are replaced
+		if convert {
+			p.add(fmt.Sprintf("ResourceQuota %s: convert to cpu %s, memory %s, storage %s, %d pods", quota.QuotaName, q.CPU, q.Memory, q.Storage, q.MaxPods), func() error {
+				return kube.Retry(func() error {
+					live, err := quotas.Get(ctx, quota.QuotaName, metav1.GetOptions{})
+					if err != nil {
+						return err
+					}
+					live.Spec.Hard = hard
+					_, err = quotas.Update(ctx, live, metav1.UpdateOptions{})
+					return err
+				})
+			})
+		}
+	}
+
+	_, err = clientset.CoreV1().
+		LimitRanges(namespace).
+		Get(ctx, LegacyLimitRange, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	legacyLimitRange := err == nil
+
+	current, err = unlabelledQuota(ctx, clientset, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if convert || legacyLimitRange || current {
+		p.add(fmt.Sprintf("ResourceQuota %s, LimitRange %s: apply", quota.QuotaName, quota.LimitRangeName), func() error {
+			return quota.EnsureQuota(clientset, namespace, q.CPU, q.Memory, q.Storage, q.MaxPods, kube.Labels(owner, ""), nil)
+		})
+	}
+
+	if legacyLimitRange {
+		p.add("LimitRange "+LegacyLimitRange+": delete (replaced by "+quota.LimitRangeName+")", func() error {
+			return remove(func(ctx context.Context) error {
+				return clientset.CoreV1().
+					LimitRanges(namespace).
+					Delete(ctx, LegacyLimitRange, metav1.DeleteOptions{})
+			})
+		})
+	}
+
+	// -------------------------
+	// Identity
+	// -------------------------
+
+	homeNamespace := namespacepkg.Name(owner, "")
+
+	_, err = clientset.RbacV1().
+		RoleBindings(namespace).
+		Get(ctx, rbac.BindingName(owner), metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	if len(p.Steps) > 0 || apierrors.IsNotFound(err) {
+		p.add("ServiceAccount, Role, RoleBinding for "+owner+": apply", func() error {
+			return rbac.EnsureRBAC(clientset, namespace, owner, homeNamespace, rbac.Developer, kube.Labels(owner, ""), nil)
+		})
+	}
+
+	return p, nil
+}
+
+// Print prints the steps of the plan.
+func (p *Plan) Print() {
+
+	if len(p.Steps) == 0 {
+		fmt.Println("No legacy layout found in", p.Namespace)
+		return
+	}
+
+	fmt.Println("Migration plan for", p.Namespace+":")
+	for i, step := range p.Steps {
+		fmt.Printf("  %d. %s\n", i+1, step.Description)
+	}
+}
+
+// Apply runs the steps of the plan in order, stopping at the first failure.
+func (p *Plan) Apply() error {
+
+	for _, step := range p.Steps {
+		err := step.Run()
+		if err != nil {
+			return fmt.Errorf("%s: %w", step.Description, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Plan) add(description string, run func() error) {
+	p.Steps = append(p.Steps, Step{Description: description, Run: run})
+}
+
+// fromHard takes the quota of a legacy ResourceQuota, preferring limits over
+// requests. Values it does not set are taken from q.
+func fromHard(hard corev1.ResourceList, q Quota) Quota {
+
+	quantity := func(names ...corev1.ResourceName) (resource.Quantity, bool) {
+		for _, name := range names {
+			if v, ok := hard[name]; ok {
+				return v, true
+			}
+		}
+		return resource.Quantity{}, false
+	}
+
+	if v, ok := quantity(corev1.ResourceLimitsCPU, corev1.ResourceRequestsCPU, corev1.ResourceCPU); ok {
+		q.CPU = v.String()
+	}
+	if v, ok := quantity(corev1.ResourceLimitsMemory, corev1.ResourceRequestsMemory, corev1.ResourceMemory); ok {
+		q.Memory = v.String()
+	}
+	if v, ok := quantity(corev1.ResourceRequestsStorage); ok {
+		q.Storage = v.String()
+	}
+	if v, ok := quantity(corev1.ResourcePods); ok {
+		q.MaxPods = int(v.Value())
+	}
+
+	return q
+}
+
+// unlabelled reports whether any of the named NetworkPolicies exists without
+// the managed-by label, as created by earlier PodCraft versions.
+func unlabelled(ctx context.Context, clientset *kubernetes.Clientset, namespace string, names []string) (bool, error) {
+
+	for _, name := range names {
+		np, err := clientset.NetworkingV1().
+			NetworkPolicies(namespace).
+			Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if np.Labels[kube.ManagedByLabel] != kube.ManagedBy {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// unlabelledQuota reports whether the ResourceQuota or LimitRange is missing
+// or lacks the managed-by label.
+func unlabelledQuota(ctx context.Context, clientset *kubernetes.Clientset, namespace string) (bool, error) {
+
+	q, err := clientset.CoreV1().
+		ResourceQuotas(namespace).
+		Get(ctx, quota.QuotaName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	lr, err := clientset.CoreV1().
+		LimitRanges(namespace).
+		Get(ctx, quota.LimitRangeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return q.Labels[kube.ManagedByLabel] != kube.ManagedBy ||
+		lr.Labels[kube.ManagedByLabel] != kube.ManagedBy, nil
+}
+
+// remove runs a delete, treating an already deleted object as success.
+func remove(del func(ctx context.Context) error) error {
+	return kube.Retry(func() error {
+		err := del(context.Background())
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+}
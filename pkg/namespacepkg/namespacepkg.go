@@ -3,48 +3,281 @@ package namespacepkg
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
 )
 
-func EnsureNamespace(clientset *kubernetes.Clientset, namespaceName, owner string) error {
+const (
+	OwnerLabel   = kube.OwnerLabel
+	EnvLabel     = "podcraft.dev/env"
+	ManagedLabel = "podcraft.dev/managed"
+	TeamLabel    = kube.TeamLabel
+	StatusLabel  = "podcraft.dev/status"
 
-	ctx := context.Background()
+	// PodSecurityLabel sets the Pod Security Standard enforced by the
+	// PodSecurity admission controller.
+	PodSecurityLabel = "pod-security.kubernetes.io/enforce"
 
-	_, err := clientset.CoreV1().
-		Namespaces().
-		Get(ctx, namespaceName, metav1.GetOptions{})
+	// PodSecurityNone removes PodSecurityLabel, leaving the Pod Security
+	// Standard to the cluster default.
+	PodSecurityNone = "none"
+
+	// ProtectedLabel set to "true" makes delete refuse the namespace.
+	ProtectedLabel = "podcraft.dev/protected"
+
+	// FailedStepAnnotation records the provisioning phase a degraded
+	// environment failed at, so repair can resume from there.
+	FailedStepAnnotation = "podcraft.dev/failed-step"
+
+	// ExpiresAnnotation records when an environment created with a TTL may
+	// be cleaned up, in RFC 3339.
+	ExpiresAnnotation = "podcraft.dev/expires-at"
+
+	// SpecAnnotation records the settings an environment was provisioned
+	// with as JSON, so reconcile re-applies them instead of flag defaults.
+	SpecAnnotation = "podcraft.dev/spec"
+
+	// BurstExpiresAnnotation records when the quota burst of an environment
+	// ends, in RFC 3339.
+	BurstExpiresAnnotation = "podcraft.dev/burst-expires-at"
+
+	StatusReady    = "ready"
+	StatusDegraded = "degraded"
+	// StatusSuspended marks an environment whose workloads were scaled to
+	// zero after it was moved to another cluster.
+	StatusSuspended = "suspended"
+
+	// DefaultEnv is the environment name of a developer's home namespace,
+	// which also holds the developer's ServiceAccount.
+	DefaultEnv = "default"
+)
+
+// Name returns the namespace of a developer environment: dev-<user> for the
+// default environment and dev-<user>-<env> for named ones.
+func Name(username, env string) string {
+	if env == "" || env == DefaultEnv {
+		return "dev-" + username
+	}
+	return "dev-" + username + "-" + env
+}
+
+// Env returns the environment name recorded on a namespace. Namespaces
+// created before environments existed are the default environment.
+func Env(ns *corev1.Namespace) string {
+	if env := ns.Labels[EnvLabel]; env != "" {
+		return env
+	}
+	return DefaultEnv
+}
+
+// Owner returns the developer a namespace belongs to. Namespaces created
+// before owner labels are dev-<owner>-<env>, or dev-<owner> for the default
+// environment, with the environment taken from their env label.
+func Owner(ns *corev1.Namespace) string {
+	if owner := ns.Labels[OwnerLabel]; owner != "" {
+		return owner
+	}
+	owner := strings.TrimPrefix(ns.Name, "dev-")
+	if env := Env(ns); env != DefaultEnv {
+		owner = strings.TrimSuffix(owner, "-"+env)
+	}
+	return owner
+}
+
+// CountEnvs returns how many environments the developer currently owns.
+func CountEnvs(clientset *kubernetes.Clientset, owner string) (int, error) {
 
+	namespaces, err := clientset.CoreV1().
+		Namespaces().
+		List(context.Background(), metav1.ListOptions{
+			LabelSelector: OwnerLabel + "=" + owner + "," + ManagedLabel + "=true",
+		})
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-
-			ns := &corev1.Namespace{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: namespaceName,
-					Labels: map[string]string{
-						"podcraft.dev/owner":   owner,
-						"podcraft.dev/managed": "true",
-					},
-				},
+		return 0, err
+	}
+
+	return len(namespaces.Items), nil
+}
+
+// EnsureNamespace creates the namespace of a developer environment and
+// reports whether it was newly created. A non-empty team labels the
+// environment as part of that team's budget, also on an existing namespace;
+// an empty team keeps the team the namespace already has. podSecurity, the
+// Pod Security Standard to enforce, is kept the same way; PodSecurityNone
+// removes it.
+func EnsureNamespace(clientset *kubernetes.Clientset, namespaceName, owner, env, team, podSecurity string, r *kube.Reporter) (bool, error) {
+
+	if env == "" {
+		env = DefaultEnv
+	}
+
+	namespaces := clientset.CoreV1().Namespaces()
+
+	labels := kube.Labels(owner, team)
+	labels[EnvLabel] = env
+	labels[ManagedLabel] = "true"
+	if podSecurity != "" && podSecurity != PodSecurityNone {
+		labels[PodSecurityLabel] = podSecurity
+	}
+
+	ns := corev1ac.Namespace(namespaceName).WithLabels(labels)
+
+	result, err := kube.Apply(
+		r,
+		func(ctx context.Context) (*corev1.Namespace, error) {
+			live, err := namespaces.Get(ctx, namespaceName, metav1.GetOptions{})
+			// Applying without the label would drop it
+			if err == nil && team == "" && live.Labels[TeamLabel] != "" {
+				ns.WithLabels(map[string]string{TeamLabel: live.Labels[TeamLabel]})
+			}
+			if err == nil && podSecurity == "" && live.Labels[PodSecurityLabel] != "" {
+				ns.WithLabels(map[string]string{PodSecurityLabel: live.Labels[PodSecurityLabel]})
 			}
+			return live, err
+		},
+		func(ctx context.Context, opts metav1.ApplyOptions) (*corev1.Namespace, error) {
+			return namespaces.Apply(ctx, ns, opts)
+		},
+		func(ns *corev1.Namespace) any {
+			return ns.Labels
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	r.Report("Namespace "+namespaceName, result)
+
+	return result == kube.Created, nil
+}
+
+// SetStatus records the provisioning status of an environment. failedStep is
+// recorded for degraded environments and cleared otherwise.
+func SetStatus(clientset *kubernetes.Clientset, namespaceName, status, failedStep string) error {
+	return Update(clientset, namespaceName, func(ns *corev1.Namespace) {
+		ns.Labels[StatusLabel] = status
+		if failedStep != "" {
+			ns.Annotations[FailedStepAnnotation] = failedStep
+		} else {
+			delete(ns.Annotations, FailedStepAnnotation)
+		}
+	})
+}
+
+// Update reads the namespace, applies mutate and writes it back, retrying on
+// conflicts with the latest version. Labels and Annotations are never nil
+// inside mutate.
+func Update(clientset *kubernetes.Clientset, namespaceName string, mutate func(ns *corev1.Namespace)) error {
+
+	namespaces := clientset.CoreV1().Namespaces()
+
+	return kube.Retry(func() error {
+
+		ctx := context.Background()
 
-			_, err = clientset.CoreV1().
+		ns, err := namespaces.Get(ctx, namespaceName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if ns.Labels == nil {
+			ns.Labels = map[string]string{}
+		}
+		if ns.Annotations == nil {
+			ns.Annotations = map[string]string{}
+		}
+
+		mutate(ns)
+
+		_, err = namespaces.Update(ctx, ns, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// Ready reports whether the namespace is active.
+func Ready(clientset *kubernetes.Clientset, namespaceName string) (bool, error) {
+
+	ns, err := clientset.CoreV1().
+		Namespaces().
+		Get(context.Background(), namespaceName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return ns.Status.Phase == corev1.NamespaceActive, nil
+}
+
+// WaitDeleted waits until the namespace is gone, printing what is still
+// holding up its termination whenever that changes.
+func WaitDeleted(clientset *kubernetes.Clientset, namespaceName string, timeout time.Duration) error {
+
+	last := ""
+
+	err := wait.PollUntilContextTimeout(context.Background(), 2*time.Second, timeout, true,
+		func(ctx context.Context) (bool, error) {
+			ns, err := clientset.CoreV1().
 				Namespaces().
-				Create(ctx, ns, metav1.CreateOptions{})
+				Get(ctx, namespaceName, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
 			if err != nil {
-				return err
+				return false, err
 			}
 
-			fmt.Println("Namespace created:", namespaceName)
-		} else {
-			return err
+			if progress := terminationProgress(ns); progress != last {
+				fmt.Println("Waiting for", namespaceName+":", progress)
+				last = progress
+			}
+
+			return false, nil
+		})
+	if wait.Interrupted(err) {
+		return fmt.Errorf("namespace %s still terminating after %s: %s", namespaceName, timeout, last)
+	}
+
+	return err
+}
+
+// terminationProgress describes what a terminating namespace is waiting for:
+// remaining content and finalizers reported by the namespace controller.
+func terminationProgress(ns *corev1.Namespace) string {
+
+	reasons := []string{}
+
+	for _, condition := range ns.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case corev1.NamespaceContentRemaining,
+			corev1.NamespaceFinalizersRemaining,
+			corev1.NamespaceDeletionContentFailure,
+			corev1.NamespaceDeletionDiscoveryFailure:
+			reasons = append(reasons, condition.Message)
 		}
-	} else {
-		fmt.Println("Namespace already exists:", namespaceName)
 	}
 
-	return nil
+	if len(ns.Spec.Finalizers) > 0 {
+		finalizers := make([]string, 0, len(ns.Spec.Finalizers))
+		for _, f := range ns.Spec.Finalizers {
+			finalizers = append(finalizers, string(f))
+		}
+		reasons = append(reasons, "finalizers "+strings.Join(finalizers, ", "))
+	}
+
+	if len(reasons) == 0 {
+		return "terminating"
+	}
+
+	return strings.Join(reasons, "; ")
 }
@@ -0,0 +1,55 @@
+package namespacepkg
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestName(t *testing.T) {
+
+	tests := []struct {
+		username, env, want string
+	}{
+		{"aman", "", "dev-aman"},
+		{"aman", DefaultEnv, "dev-aman"},
+		{"aman", "feature-x", "dev-aman-feature-x"},
+		{"aman-feature", "x", "dev-aman-feature-x"},
+	}
+
+	for _, tt := range tests {
+		if got := Name(tt.username, tt.env); got != tt.want {
+			t.Errorf("Name(%q, %q) = %q, want %q", tt.username, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestOwnerAndEnv(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		namespace string
+		labels    map[string]string
+		owner     string
+		env       string
+	}{
+		{"labelled default", "dev-aman", map[string]string{OwnerLabel: "aman", EnvLabel: DefaultEnv}, "aman", DefaultEnv},
+		{"labelled named", "dev-aman-feature-x", map[string]string{OwnerLabel: "aman", EnvLabel: "feature-x"}, "aman", "feature-x"},
+		{"ambiguous name, labelled", "dev-aman-feature-x", map[string]string{OwnerLabel: "aman-feature", EnvLabel: "x"}, "aman-feature", "x"},
+		{"env label only", "dev-aman-feature-x", map[string]string{EnvLabel: "feature-x"}, "aman", "feature-x"},
+		{"unlabelled", "dev-aman-x", nil, "aman-x", DefaultEnv},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.namespace, Labels: tt.labels}}
+			if got := Owner(ns); got != tt.owner {
+				t.Errorf("Owner() = %q, want %q", got, tt.owner)
+			}
+			if got := Env(ns); got != tt.env {
+				t.Errorf("Env() = %q, want %q", got, tt.env)
+			}
+		})
+	}
+}
@@ -2,186 +2,125 @@ package network
 
 import (
 	"context"
-	"fmt"
-	"reflect"
 
 	networkingv1 "k8s.io/api/networking/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1ac "k8s.io/client-go/applyconfigurations/meta/v1"
+	networkingv1ac "k8s.io/client-go/applyconfigurations/networking/v1"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
 )
 
-func EnsureNetwork(clientset *kubernetes.Clientset, namespace string) error {
+// SharedLabel marks namespaces whose pods may reach developer environments.
+const SharedLabel = "podcraft.dev/shared"
 
-	ctx := context.Background()
+// PolicyNames are the NetworkPolicies EnsureNetwork manages.
+var PolicyNames = []string{
+	"default-deny",
+	"allow-same-namespace",
+	"allow-shared-services",
+}
 
-	// -------------------------
-	// 1. Default Deny
-	// -------------------------
+// policy is a NetworkPolicy to apply and how to describe it in output.
+type policy struct {
+	description string
+	config      *networkingv1ac.NetworkPolicyApplyConfiguration
+}
 
-	defaultDeny := &networkingv1.NetworkPolicy{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "default-deny",
-			Namespace: namespace,
+func desiredPolicies(namespace string) []policy {
+	return []policy{
+		// -------------------------
+		// 1. Default Deny
+		// -------------------------
+		{
+			description: "Default deny policy",
+			config: networkingv1ac.NetworkPolicy("default-deny", namespace).
+				WithSpec(networkingv1ac.NetworkPolicySpec().
+					WithPodSelector(metav1ac.LabelSelector()).
+					WithPolicyTypes(networkingv1.PolicyTypeIngress)),
 		},
-		Spec: networkingv1.NetworkPolicySpec{
-			PodSelector: metav1.LabelSelector{},
-			PolicyTypes: []networkingv1.PolicyType{
-				networkingv1.PolicyTypeIngress,
-			},
+
+		// -------------------------
+		// 2. Allow Same Namespace
+		// -------------------------
+		{
+			description: "Intra-namespace policy",
+			config: networkingv1ac.NetworkPolicy("allow-same-namespace", namespace).
+				WithSpec(networkingv1ac.NetworkPolicySpec().
+					WithPodSelector(metav1ac.LabelSelector()).
+					WithIngress(networkingv1ac.NetworkPolicyIngressRule().
+						WithFrom(networkingv1ac.NetworkPolicyPeer().
+							WithPodSelector(metav1ac.LabelSelector()))).
+					WithPolicyTypes(networkingv1.PolicyTypeIngress)),
 		},
-	}
 
-	existingDeny, err := clientset.NetworkingV1().
-		NetworkPolicies(namespace).
-		Get(ctx, "default-deny", metav1.GetOptions{})
-
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			_, err = clientset.NetworkingV1().
-				NetworkPolicies(namespace).
-				Create(ctx, defaultDeny, metav1.CreateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("Default deny policy created")
-		} else {
-			return err
-		}
-	} else {
-		if !reflect.DeepEqual(existingDeny.Spec, defaultDeny.Spec) {
-			existingDeny.Spec = defaultDeny.Spec
-			_, err = clientset.NetworkingV1().
-				NetworkPolicies(namespace).
-				Update(ctx, existingDeny, metav1.UpdateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("Default deny policy updated")
-		} else {
-			fmt.Println("Default deny policy already matches desired state")
-		}
+		// -------------------------
+		// 3. Allow Shared Services
+		// -------------------------
+		{
+			description: "Shared namespace policy",
+			config: networkingv1ac.NetworkPolicy("allow-shared-services", namespace).
+				WithSpec(networkingv1ac.NetworkPolicySpec().
+					WithPodSelector(metav1ac.LabelSelector()).
+					WithIngress(networkingv1ac.NetworkPolicyIngressRule().
+						WithFrom(networkingv1ac.NetworkPolicyPeer().
+							WithNamespaceSelector(metav1ac.LabelSelector().
+								WithMatchLabels(map[string]string{
+									SharedLabel: "true",
+								})))).
+					WithPolicyTypes(networkingv1.PolicyTypeIngress)),
+		},
 	}
+}
 
-	// -------------------------
-	// 2. Allow Same Namespace
-	// -------------------------
+// EnsureNetwork applies the NetworkPolicies of namespace, labelled with
+// labels and annotated with the hash of their spec.
+func EnsureNetwork(clientset *kubernetes.Clientset, namespace string, labels map[string]string, r *kube.Reporter) error {
 
-	allowInternal := &networkingv1.NetworkPolicy{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "allow-same-namespace",
-			Namespace: namespace,
-		},
-		Spec: networkingv1.NetworkPolicySpec{
-			PodSelector: metav1.LabelSelector{},
-			Ingress: []networkingv1.NetworkPolicyIngressRule{
-				{
-					From: []networkingv1.NetworkPolicyPeer{
-						{
-							PodSelector: &metav1.LabelSelector{},
-						},
-					},
-				},
-			},
-			PolicyTypes: []networkingv1.PolicyType{
-				networkingv1.PolicyTypeIngress,
-			},
-		},
-	}
+	client := clientset.NetworkingV1().NetworkPolicies(namespace)
 
-	existingInternal, err := clientset.NetworkingV1().
-		NetworkPolicies(namespace).
-		Get(ctx, "allow-same-namespace", metav1.GetOptions{})
-
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			_, err = clientset.NetworkingV1().
-				NetworkPolicies(namespace).
-				Create(ctx, allowInternal, metav1.CreateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("Intra-namespace policy created")
-		} else {
-			return err
-		}
-	} else {
-		if !reflect.DeepEqual(existingInternal.Spec, allowInternal.Spec) {
-			existingInternal.Spec = allowInternal.Spec
-			_, err = clientset.NetworkingV1().
-				NetworkPolicies(namespace).
-				Update(ctx, existingInternal, metav1.UpdateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("Intra-namespace policy updated")
-		} else {
-			fmt.Println("Intra-namespace policy already matches desired state")
-		}
-	}
+	for _, p := range desiredPolicies(namespace) {
 
-	// -------------------------
-	// 3. Allow Shared Services
-	// -------------------------
+		p.config.
+			WithLabels(labels).
+			WithAnnotations(kube.Annotations(p.config.Spec))
 
-	allowShared := &networkingv1.NetworkPolicy{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "allow-shared-services",
-			Namespace: namespace,
-		},
-		Spec: networkingv1.NetworkPolicySpec{
-			PodSelector: metav1.LabelSelector{},
-			Ingress: []networkingv1.NetworkPolicyIngressRule{
-				{
-					From: []networkingv1.NetworkPolicyPeer{
-						{
-							NamespaceSelector: &metav1.LabelSelector{
-								MatchLabels: map[string]string{
-									"podcraft.dev/shared": "true",
-								},
-							},
-						},
-					},
-				},
+		result, err := kube.Apply(
+			r,
+			func(ctx context.Context) (*networkingv1.NetworkPolicy, error) {
+				return client.Get(ctx, *p.config.Name, metav1.GetOptions{})
 			},
-			PolicyTypes: []networkingv1.PolicyType{
-				networkingv1.PolicyTypeIngress,
+			func(ctx context.Context, opts metav1.ApplyOptions) (*networkingv1.NetworkPolicy, error) {
+				return client.Apply(ctx, p.config, opts)
 			},
-		},
-	}
-
-	existingShared, err := clientset.NetworkingV1().
-		NetworkPolicies(namespace).
-		Get(ctx, "allow-shared-services", metav1.GetOptions{})
-
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			_, err = clientset.NetworkingV1().
-				NetworkPolicies(namespace).
-				Create(ctx, allowShared, metav1.CreateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("Shared namespace policy created")
-		} else {
+			func(np *networkingv1.NetworkPolicy) any {
+				return np.Spec
+			},
+		)
+		if err != nil {
 			return err
 		}
-	} else {
-		if !reflect.DeepEqual(existingShared.Spec, allowShared.Spec) {
-			existingShared.Spec = allowShared.Spec
-			_, err = clientset.NetworkingV1().
-				NetworkPolicies(namespace).
-				Update(ctx, existingShared, metav1.UpdateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("Shared namespace policy updated")
-		} else {
-			fmt.Println("Shared namespace policy already matches desired state")
-		}
+
+		r.Report(p.description, result)
 	}
 
-	fmt.Println("NetworkPolicies ensured")
+	r.Println("NetworkPolicies ensured")
 
 	return nil
 }
+
+// Ready reports whether all managed NetworkPolicies exist.
+func Ready(clientset *kubernetes.Clientset, namespace string) (bool, error) {
+
+	for _, name := range PolicyNames {
+		_, err := clientset.NetworkingV1().
+			NetworkPolicies(namespace).
+			Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,123 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+)
+
+// ReadyTimeout bounds how long a phase may take to become ready.
+var ReadyTimeout = 2 * time.Minute
+
+// Phase is one stage of provisioning an environment. Ready, if set, is polled
+// after Run until it reports true, so the next phase only starts once the
+// objects of this one are in effect.
+type Phase struct {
+	Name  string
+	Run   func() error
+	Ready func() (bool, error)
+}
+
+// Pipeline runs phases in order and remembers how to undo what they created.
+type Pipeline struct {
+	Phases []Phase
+
+	undo []undoFunc
+}
+
+type undoFunc struct {
+	description string
+	run         func() error
+}
+
+// FailedError is returned by Run when a phase fails.
+type FailedError struct {
+	Phase string
+	Err   error
+}
+
+func (e *FailedError) Error() string {
+	return fmt.Sprintf("phase %s failed: %v", e.Phase, e.Err)
+}
+
+func (e *FailedError) Unwrap() error {
+	return e.Err
+}
+
+// Created registers how to remove an object a phase newly created, so it can
+// be rolled back if a later phase fails. Objects that already existed must
+// not be registered.
+func (p *Pipeline) Created(description string, undo func() error) {
+	p.undo = append(p.undo, undoFunc{description: description, run: undo})
+}
+
+// Has reports whether the pipeline has a phase with the given name.
+func (p *Pipeline) Has(name string) bool {
+	return slices.ContainsFunc(p.Phases, func(ph Phase) bool { return ph.Name == name })
+}
+
+// Skip removes the phase with the given name from the pipeline.
+func (p *Pipeline) Skip(name string) {
+	p.Phases = slices.DeleteFunc(p.Phases, func(ph Phase) bool { return ph.Name == name })
+}
+
+// Run runs the phases in order, starting at the phase named from (all phases
+// if from is empty). A failing or never-ready phase stops the pipeline with a
+// *FailedError.
+func (p *Pipeline) Run(from string) error {
+
+	start := 0
+	if from != "" {
+		start = slices.IndexFunc(p.Phases, func(ph Phase) bool { return ph.Name == from })
+		if start < 0 {
+			return fmt.Errorf("unknown phase %q", from)
+		}
+	}
+
+	for _, phase := range p.Phases[start:] {
+		if err := phase.Run(); err != nil {
+			return &FailedError{Phase: phase.Name, Err: err}
+		}
+
+		if phase.Ready == nil {
+			continue
+		}
+
+		err := wait.PollUntilContextTimeout(context.Background(), time.Second, ReadyTimeout, true,
+			func(ctx context.Context) (bool, error) {
+				return phase.Ready()
+			})
+		if err != nil {
+			return &FailedError{Phase: phase.Name, Err: fmt.Errorf("not ready: %w", err)}
+		}
+	}
+
+	return nil
+}
+
+// CanRollback reports whether any phase created something.
+func (p *Pipeline) CanRollback() bool {
+	return len(p.undo) > 0
+}
+
+// Rollback removes everything registered with Created, newest first, and
+// prints each removal to r.
+func (p *Pipeline) Rollback(r *kube.Reporter) error {
+
+	for i := len(p.undo) - 1; i >= 0; i-- {
+		u := p.undo[i]
+		if err := u.run(); err != nil {
+			return fmt.Errorf("rolling back %s: %w", u.description, err)
+		}
+		r.Println("Rolled back:", u.description)
+	}
+
+	p.undo = nil
+
+	return nil
+}
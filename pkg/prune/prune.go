@@ -0,0 +1,206 @@
+package prune
+
+import (
+	"context"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+)
+
+// Kinds of objects that are pruned.
+const (
+	NetworkPolicy = "NetworkPolicy"
+	Role          = "Role"
+	RoleBinding   = "RoleBinding"
+	ResourceQuota = "ResourceQuota"
+	LimitRange    = "LimitRange"
+)
+
+// Desired is the set of object names PodCraft manages in a namespace, keyed
+// by kind. Kinds missing from the set are not pruned.
+type Desired map[string][]string
+
+// Object is a PodCraft-managed object that is no longer desired.
+type Object struct {
+	Kind string
+	Name string
+}
+
+// kind lists and deletes the objects of one kind in a namespace.
+type kind struct {
+	name   string
+	list   func(ctx context.Context, clientset *kubernetes.Clientset, namespace string, opts metav1.ListOptions) ([]string, error)
+	delete func(ctx context.Context, clientset *kubernetes.Clientset, namespace, name string) error
+}
+
+var kinds = []kind{
+	{
+		name: NetworkPolicy,
+		list: func(ctx context.Context, clientset *kubernetes.Clientset, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.NetworkingV1().NetworkPolicies(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(ctx context.Context, clientset *kubernetes.Clientset, namespace, name string) error {
+			return clientset.NetworkingV1().NetworkPolicies(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	},
+	{
+		name: Role,
+		list: func(ctx context.Context, clientset *kubernetes.Clientset, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.RbacV1().Roles(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(ctx context.Context, clientset *kubernetes.Clientset, namespace, name string) error {
+			return clientset.RbacV1().Roles(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	},
+	{
+		name: RoleBinding,
+		list: func(ctx context.Context, clientset *kubernetes.Clientset, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.RbacV1().RoleBindings(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(ctx context.Context, clientset *kubernetes.Clientset, namespace, name string) error {
+			return clientset.RbacV1().RoleBindings(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	},
+	{
+		name: ResourceQuota,
+		list: func(ctx context.Context, clientset *kubernetes.Clientset, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.CoreV1().ResourceQuotas(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(ctx context.Context, clientset *kubernetes.Clientset, namespace, name string) error {
+			return clientset.CoreV1().ResourceQuotas(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	},
+	{
+		name: LimitRange,
+		list: func(ctx context.Context, clientset *kubernetes.Clientset, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.CoreV1().LimitRanges(namespace).List(ctx, opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(ctx context.Context, clientset *kubernetes.Clientset, namespace, name string) error {
+			return clientset.CoreV1().LimitRanges(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+		},
+	},
+}
+
+// Stale returns the objects in namespace labelled as managed by PodCraft
+// that are not in the desired set. Objects without the managed-by label,
+// such as those of developers or other tools, are never returned.
+func Stale(clientset *kubernetes.Clientset, namespace string, desired Desired) ([]Object, error) {
+
+	ctx := context.Background()
+
+	opts := metav1.ListOptions{
+		LabelSelector: kube.ManagedByLabel + "=" + kube.ManagedBy,
+	}
+
+	stale := []Object{}
+
+	for _, k := range kinds {
+		wanted, ok := desired[k.name]
+		if !ok {
+			continue
+		}
+
+		names, err := k.list(ctx, clientset, namespace, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range names {
+			if !slices.Contains(wanted, name) {
+				stale = append(stale, Object{Kind: k.name, Name: name})
+			}
+		}
+	}
+
+	return stale, nil
+}
+
+// Prune prints the plan of stale objects in namespace to r and, if apply is
+// set, deletes them.
+func Prune(clientset *kubernetes.Clientset, namespace string, desired Desired, apply bool, r *kube.Reporter) error {
+
+	stale, err := Stale(clientset, namespace, desired)
+	if err != nil {
+		return err
+	}
+
+	if len(stale) == 0 {
+		r.Println("No stale PodCraft objects")
+		return nil
+	}
+
+	if !apply {
+		r.Println("Stale PodCraft objects (use --prune to delete):")
+		for _, obj := range stale {
+			r.Printf("  - %s %s\n", obj.Kind, obj.Name)
+		}
+		return nil
+	}
+
+	r.Println("Pruning stale PodCraft objects:")
+
+	ctx := context.Background()
+
+	for _, obj := range stale {
+		i := slices.IndexFunc(kinds, func(k kind) bool { return k.name == obj.Kind })
+
+		err := kube.Retry(func() error {
+			err := kinds[i].delete(ctx, clientset, namespace, obj.Name)
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		r.Printf("  - %s %s pruned\n", obj.Kind, obj.Name)
+	}
+
+	return nil
+}
@@ -3,154 +3,237 @@ package quota
 import (
 	"context"
 	"fmt"
-	"reflect"
+	"slices"
+	"strings"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	resource "k8s.io/apimachinery/pkg/api/resource"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
 )
 
-func EnsureQuota(clientset *kubernetes.Clientset, namespace string, cpuLimit string, memoryLimit string, maxPods int) error {
+// Names of the objects EnsureQuota manages.
+const (
+	QuotaName      = "dev-quota"
+	LimitRangeName = "dev-limitrange"
 
-	ctx := context.Background()
+	// DefaultStorage is the storage quota of an environment unless given.
+	DefaultStorage = "5Gi"
+)
 
-	// -------------------------
-	// ResourceQuota
-	// -------------------------
+// Hard returns the hard limits of the dev-quota ResourceQuota. An empty
+// storageLimit is DefaultStorage.
+func Hard(cpuLimit string, memoryLimit string, storageLimit string, maxPods int) (corev1.ResourceList, error) {
 
-	quota := &corev1.ResourceQuota{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "dev-quota",
-			Namespace: namespace,
-		},
-		Spec: corev1.ResourceQuotaSpec{
-			Hard: corev1.ResourceList{
-				corev1.ResourcePods:            resource.MustParse(fmt.Sprintf("%d", maxPods)),
-				corev1.ResourceLimitsCPU:       resource.MustParse(cpuLimit),
-				corev1.ResourceLimitsMemory:    resource.MustParse(memoryLimit),
-				corev1.ResourceRequestsStorage: resource.MustParse("5Gi"),
-			},
-		},
+	cpu, err := resource.ParseQuantity(cpuLimit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CPU limit %q: %w", cpuLimit, err)
 	}
 
-	existingQuota, err := clientset.CoreV1().
-		ResourceQuotas(namespace).
-		Get(ctx, "dev-quota", metav1.GetOptions{})
+	memory, err := resource.ParseQuantity(memoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("invalid memory limit %q: %w", memoryLimit, err)
+	}
+
+	if storageLimit == "" {
+		storageLimit = DefaultStorage
+	}
 
+	storage, err := resource.ParseQuantity(storageLimit)
 	if err != nil {
+		return nil, fmt.Errorf("invalid storage limit %q: %w", storageLimit, err)
+	}
+
+	return corev1.ResourceList{
+		corev1.ResourcePods:            *resource.NewQuantity(int64(maxPods), resource.DecimalSI),
+		corev1.ResourceLimitsCPU:       cpu,
+		corev1.ResourceLimitsMemory:    memory,
+		corev1.ResourceRequestsStorage: storage,
+	}, nil
+}
+
+// HardByNamespace returns the dev-quota hard limits of every namespace
+// matching the label selector, keyed by namespace.
+func HardByNamespace(clientset *kubernetes.Clientset, labelSelector string) (map[string]corev1.ResourceList, error) {
+
+	ctx := context.Background()
+
+	namespaces, err := clientset.CoreV1().
+		Namespaces().
+		List(ctx, metav1.ListOptions{
+			LabelSelector: labelSelector,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	hard := map[string]corev1.ResourceList{}
+
+	for _, ns := range namespaces.Items {
+		existing, err := clientset.CoreV1().
+			ResourceQuotas(ns.Name).
+			Get(ctx, QuotaName, metav1.GetOptions{})
 		if apierrors.IsNotFound(err) {
-			_, err = clientset.CoreV1().
-				ResourceQuotas(namespace).
-				Create(ctx, quota, metav1.CreateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("ResourceQuota created")
-		} else {
-			return err
+			continue
 		}
-	} else {
-
-		desiredCPU := resource.MustParse(cpuLimit)
-		desiredMemory := resource.MustParse(memoryLimit)
-		desiredPods := resource.MustParse(fmt.Sprintf("%d", maxPods))
-		desiredStorage := resource.MustParse("5Gi")
-
-		currentCPU := existingQuota.Spec.Hard[corev1.ResourceLimitsCPU]
-		currentMemory := existingQuota.Spec.Hard[corev1.ResourceLimitsMemory]
-		currentPods := existingQuota.Spec.Hard[corev1.ResourcePods]
-		currentStorage := existingQuota.Spec.Hard[corev1.ResourceRequestsStorage]
-
-		if currentCPU.Cmp(desiredCPU) != 0 ||
-			currentMemory.Cmp(desiredMemory) != 0 ||
-			currentPods.Cmp(desiredPods) != 0 ||
-			currentStorage.Cmp(desiredStorage) != 0 {
-
-			existingQuota.Spec.Hard[corev1.ResourceLimitsCPU] = desiredCPU
-			existingQuota.Spec.Hard[corev1.ResourceLimitsMemory] = desiredMemory
-			existingQuota.Spec.Hard[corev1.ResourcePods] = desiredPods
-			existingQuota.Spec.Hard[corev1.ResourceRequestsStorage] = desiredStorage
-
-			_, err = clientset.CoreV1().
-				ResourceQuotas(namespace).
-				Update(ctx, existingQuota, metav1.UpdateOptions{})
-			if err != nil {
-				return err
-			}
-
-			fmt.Println("ResourceQuota updated to match desired state")
-		} else {
-			fmt.Println("ResourceQuota already matches desired state")
+		if err != nil {
+			return nil, err
 		}
+		hard[ns.Name] = existing.Spec.Hard
+	}
+
+	return hard, nil
+}
+
+// EnsureQuota applies the dev-quota ResourceQuota and dev-limitrange
+// LimitRange of namespace, labelled with labels and annotated with the hash
+// of their spec.
+func EnsureQuota(clientset *kubernetes.Clientset, namespace string, cpuLimit string, memoryLimit string, storageLimit string, maxPods int, labels map[string]string, r *kube.Reporter) error {
+
+	hard, err := Hard(cpuLimit, memoryLimit, storageLimit, maxPods)
+	if err != nil {
+		return err
+	}
+
+	// -------------------------
+	// ResourceQuota
+	// -------------------------
+
+	quotas := clientset.CoreV1().ResourceQuotas(namespace)
+
+	quota := corev1ac.ResourceQuota(QuotaName, namespace).
+		WithSpec(corev1ac.ResourceQuotaSpec().
+			WithHard(hard))
+	quota.WithLabels(labels).
+		WithAnnotations(kube.Annotations(quota.Spec))
+
+	result, err := kube.Apply(
+		r,
+		func(ctx context.Context) (*corev1.ResourceQuota, error) {
+			return quotas.Get(ctx, QuotaName, metav1.GetOptions{})
+		},
+		func(ctx context.Context, opts metav1.ApplyOptions) (*corev1.ResourceQuota, error) {
+			return quotas.Apply(ctx, quota, opts)
+		},
+		func(q *corev1.ResourceQuota) any {
+			return q.Spec
+		},
+	)
+	if err != nil {
+		return err
 	}
 
+	r.Report("ResourceQuota", result)
+
 	// -------------------------
 	// LimitRange
 	// -------------------------
 
-	limitRange := &corev1.LimitRange{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "dev-limitrange",
-			Namespace: namespace,
+	limitRanges := clientset.CoreV1().LimitRanges(namespace)
+
+	limitRange := corev1ac.LimitRange(LimitRangeName, namespace).
+		WithSpec(corev1ac.LimitRangeSpec().
+			WithLimits(corev1ac.LimitRangeItem().
+				WithType(corev1.LimitTypeContainer).
+				WithDefaultRequest(corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("100m"),
+					corev1.ResourceMemory: resource.MustParse("128Mi"),
+				}).
+				WithDefault(corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("500m"),
+					corev1.ResourceMemory: resource.MustParse("512Mi"),
+				}).
+				WithMax(corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("1"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				})))
+	limitRange.WithLabels(labels).
+		WithAnnotations(kube.Annotations(limitRange.Spec))
+
+	result, err = kube.Apply(
+		r,
+		func(ctx context.Context) (*corev1.LimitRange, error) {
+			return limitRanges.Get(ctx, LimitRangeName, metav1.GetOptions{})
 		},
-		Spec: corev1.LimitRangeSpec{
-			Limits: []corev1.LimitRangeItem{
-				{
-					Type: corev1.LimitTypeContainer,
-					DefaultRequest: corev1.ResourceList{
-						corev1.ResourceCPU:    resource.MustParse("100m"),
-						corev1.ResourceMemory: resource.MustParse("128Mi"),
-					},
-					Default: corev1.ResourceList{
-						corev1.ResourceCPU:    resource.MustParse("500m"),
-						corev1.ResourceMemory: resource.MustParse("512Mi"),
-					},
-					Max: corev1.ResourceList{
-						corev1.ResourceCPU:    resource.MustParse("1"),
-						corev1.ResourceMemory: resource.MustParse("1Gi"),
-					},
-				},
-			},
+		func(ctx context.Context, opts metav1.ApplyOptions) (*corev1.LimitRange, error) {
+			return limitRanges.Apply(ctx, limitRange, opts)
 		},
+		func(lr *corev1.LimitRange) any {
+			return lr.Spec
+		},
+	)
+	if err != nil {
+		return err
 	}
 
-	existingLR, err := clientset.CoreV1().
-		LimitRanges(namespace).
-		Get(ctx, "dev-limitrange", metav1.GetOptions{})
+	r.Report("LimitRange", result)
+
+	return nil
+}
+
+// CheckUsage returns an error naming every resource whose usage in
+// namespace, as observed by the quota controller, exceeds hard. A namespace
+// without a quota has no recorded usage.
+func CheckUsage(clientset *kubernetes.Clientset, namespace string, hard corev1.ResourceList) error {
 
+	existing, err := clientset.CoreV1().
+		ResourceQuotas(namespace).
+		Get(context.Background(), QuotaName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			_, err = clientset.CoreV1().
-				LimitRanges(namespace).
-				Create(ctx, limitRange, metav1.CreateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("LimitRange created")
-		} else {
-			return err
+		return err
+	}
+
+	below := []string{}
+
+	for name, limit := range hard {
+		used, ok := existing.Status.Used[name]
+		if ok && used.Cmp(limit) > 0 {
+			below = append(below, fmt.Sprintf("%s %s is below the %s in use", name, limit.String(), used.String()))
 		}
-	} else {
+	}
 
-		if !reflect.DeepEqual(existingLR.Spec, limitRange.Spec) {
+	if len(below) > 0 {
+		slices.Sort(below)
+		return fmt.Errorf("quota of %s below current usage: %s", namespace, strings.Join(below, "; "))
+	}
 
-			existingLR.Spec = limitRange.Spec
+	return nil
+}
+
+// Ready reports whether the quota is enforced, i.e. the quota controller has
+// observed the current hard limits, and the LimitRange exists.
+func Ready(clientset *kubernetes.Clientset, namespace string) (bool, error) {
+
+	ctx := context.Background()
 
-			_, err = clientset.CoreV1().
-				LimitRanges(namespace).
-				Update(ctx, existingLR, metav1.UpdateOptions{})
-			if err != nil {
-				return err
-			}
+	existingQuota, err := clientset.CoreV1().
+		ResourceQuotas(namespace).
+		Get(ctx, QuotaName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
 
-			fmt.Println("LimitRange updated")
-		} else {
-			fmt.Println("LimitRange already matches desired state")
+	for name, hard := range existingQuota.Spec.Hard {
+		observed, ok := existingQuota.Status.Hard[name]
+		if !ok || observed.Cmp(hard) != 0 {
+			return false, nil
 		}
 	}
 
-	return nil
+	_, err = clientset.CoreV1().
+		LimitRanges(namespace).
+		Get(ctx, LimitRangeName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
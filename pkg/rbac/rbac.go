@@ -3,155 +3,237 @@ package rbac
 import (
 	"context"
 	"fmt"
-	"reflect"
 
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	rbacv1ac "k8s.io/client-go/applyconfigurations/rbac/v1"
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
 )
 
-func EnsureRBAC(clientset *kubernetes.Clientset, namespace, username string) error {
+// Level is the access level a developer is granted in a namespace.
+type Level string
 
-	ctx := context.Background()
+const (
+	// Developer can manage workloads, services and PVCs.
+	Developer Level = "developer"
+	// Lead can additionally manage Secrets and ConfigMaps and view quotas.
+	Lead Level = "lead"
+)
 
-	// ServiceAccount
-	sa := &corev1.ServiceAccount{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      username,
-			Namespace: namespace,
+// RoleName returns the name of the Role granting username access.
+func RoleName(username string) string {
+	return username + "-role"
+}
+
+// BindingName returns the name of the RoleBinding granting username access.
+func BindingName(username string) string {
+	return username + "-binding"
+}
+
+// Rules returns the Role rules for an access level.
+func Rules(level Level) []rbacv1.PolicyRule {
+
+	rules := []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{"", "apps"},
+			Resources: []string{
+				"pods",
+				"services",
+				"deployments",
+				"persistentvolumeclaims",
+			},
+			Verbs: []string{"get", "list", "watch", "create", "delete", "update"},
 		},
 	}
 
-	_, err := clientset.CoreV1().
-		ServiceAccounts(namespace).
-		Get(ctx, username, metav1.GetOptions{})
+	if level == Lead {
+		rules = append(rules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{"secrets", "configmaps"},
+				Verbs:     []string{"get", "list", "watch", "create", "delete", "update"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{"resourcequotas", "limitranges"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+		)
+	}
 
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			_, err = clientset.CoreV1().
-				ServiceAccounts(namespace).
-				Create(ctx, sa, metav1.CreateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("ServiceAccount created")
-		} else {
+	return rules
+}
+
+// EnsureRBAC grants username access to namespace at the given level. The
+// developer's identity is the ServiceAccount username in identityNamespace;
+// it is only created here when identityNamespace is namespace itself, so
+// every environment of a developer binds the same ServiceAccount. The objects
+// are labelled with labels and annotated with the hash of their spec.
+func EnsureRBAC(clientset *kubernetes.Clientset, namespace, username, identityNamespace string, level Level, labels map[string]string, r *kube.Reporter) error {
+
+	// ServiceAccount
+	serviceAccounts := clientset.CoreV1().ServiceAccounts(identityNamespace)
+
+	if identityNamespace == namespace {
+		sa := corev1ac.ServiceAccount(username, namespace)
+		sa.WithLabels(labels).
+			WithAnnotations(kube.Annotations([]any{sa.AutomountServiceAccountToken, sa.ImagePullSecrets}))
+
+		result, err := kube.Apply(
+			r,
+			func(ctx context.Context) (*corev1.ServiceAccount, error) {
+				return serviceAccounts.Get(ctx, username, metav1.GetOptions{})
+			},
+			func(ctx context.Context, opts metav1.ApplyOptions) (*corev1.ServiceAccount, error) {
+				return serviceAccounts.Apply(ctx, sa, opts)
+			},
+			func(sa *corev1.ServiceAccount) any {
+				return sa.Labels
+			},
+		)
+		if err != nil {
 			return err
 		}
+
+		r.Report("ServiceAccount", result)
 	} else {
-		fmt.Println("ServiceAccount already exists:", username)
+		_, err := serviceAccounts.Get(context.Background(), username, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("ServiceAccount %s not found in %s", username, identityNamespace)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 	// Role
-	role := &rbacv1.Role{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      username + "-role",
-			Namespace: namespace,
-		},
-		Rules: []rbacv1.PolicyRule{
-			{
-				APIGroups: []string{"", "apps"},
-				Resources: []string{
-					"pods",
-					"services",
-					"deployments",
-					"persistentvolumeclaims",
-				},
-				Verbs: []string{"get", "list", "watch", "create", "delete", "update"},
-			},
-		},
+	roles := clientset.RbacV1().Roles(namespace)
+
+	role := rbacv1ac.Role(RoleName(username), namespace)
+	for _, rule := range Rules(level) {
+		role.WithRules(rbacv1ac.PolicyRule().
+			WithAPIGroups(rule.APIGroups...).
+			WithResources(rule.Resources...).
+			WithVerbs(rule.Verbs...))
 	}
+	role.WithLabels(labels).
+		WithAnnotations(kube.Annotations(role.Rules))
 
-	existingRole, err := clientset.RbacV1().
-		Roles(namespace).
-		Get(ctx, username+"-role", metav1.GetOptions{})
-
+	result, err := kube.Apply(
+		r,
+		func(ctx context.Context) (*rbacv1.Role, error) {
+			return roles.Get(ctx, RoleName(username), metav1.GetOptions{})
+		},
+		func(ctx context.Context, opts metav1.ApplyOptions) (*rbacv1.Role, error) {
+			return roles.Apply(ctx, role, opts)
+		},
+		func(r *rbacv1.Role) any {
+			return r.Rules
+		},
+	)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			_, err = clientset.RbacV1().
-				Roles(namespace).
-				Create(ctx, role, metav1.CreateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("Role created")
-		} else {
-			return err
-		}
-	} else {
-
-		if !reflect.DeepEqual(existingRole.Rules, role.Rules) {
-			existingRole.Rules = role.Rules
-			_, err = clientset.RbacV1().
-				Roles(namespace).
-				Update(ctx, existingRole, metav1.UpdateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("Role updated")
-		} else {
-			fmt.Println("Role already matches desired state")
-		}
+		return err
 	}
 
+	r.Report("Role", result)
+
 	// RoleBinding
-	roleBinding := &rbacv1.RoleBinding{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      username + "-binding",
-			Namespace: namespace,
+	roleBindings := clientset.RbacV1().RoleBindings(namespace)
+
+	roleBinding := rbacv1ac.RoleBinding(BindingName(username), namespace).
+		WithSubjects(rbacv1ac.Subject().
+			WithKind("ServiceAccount").
+			WithName(username).
+			WithNamespace(identityNamespace)).
+		WithRoleRef(rbacv1ac.RoleRef().
+			WithKind("Role").
+			WithName(RoleName(username)).
+			WithAPIGroup("rbac.authorization.k8s.io"))
+	roleBinding.WithLabels(labels).
+		WithAnnotations(kube.Annotations([]any{roleBinding.Subjects, roleBinding.RoleRef}))
+
+	result, err = kube.Apply(
+		r,
+		func(ctx context.Context) (*rbacv1.RoleBinding, error) {
+			return roleBindings.Get(ctx, BindingName(username), metav1.GetOptions{})
 		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:      "ServiceAccount",
-				Name:      username,
-				Namespace: namespace,
-			},
+		func(ctx context.Context, opts metav1.ApplyOptions) (*rbacv1.RoleBinding, error) {
+			return roleBindings.Apply(ctx, roleBinding, opts)
 		},
-		RoleRef: rbacv1.RoleRef{
-			Kind:     "Role",
-			Name:     username + "-role",
-			APIGroup: "rbac.authorization.k8s.io",
+		func(rb *rbacv1.RoleBinding) any {
+			return []any{rb.Subjects, rb.RoleRef}
 		},
+	)
+	if err != nil {
+		return err
 	}
 
-	existingRB, err := clientset.RbacV1().
-		RoleBindings(namespace).
-		Get(ctx, username+"-binding", metav1.GetOptions{})
+	r.Report("RoleBinding", result)
 
+	return nil
+}
+
+// RemoveRBAC revokes username's access to namespace by deleting the Role and
+// RoleBinding. The ServiceAccount lives in the developer's own environment and
+// is left alone.
+func RemoveRBAC(clientset *kubernetes.Clientset, namespace, username string) error {
+
+	ctx := context.Background()
+
+	err := kube.Retry(func() error {
+		err := clientset.RbacV1().
+			RoleBindings(namespace).
+			Delete(ctx, BindingName(username), metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
 	if err != nil {
+		return err
+	}
+
+	err = kube.Retry(func() error {
+		err := clientset.RbacV1().
+			Roles(namespace).
+			Delete(ctx, RoleName(username), metav1.DeleteOptions{})
 		if apierrors.IsNotFound(err) {
-			_, err = clientset.RbacV1().
-				RoleBindings(namespace).
-				Create(ctx, roleBinding, metav1.CreateOptions{})
-			if err != nil {
-				return err
-			}
-			fmt.Println("RoleBinding created")
-		} else {
-			return err
+			return nil
 		}
-	} else {
+		return err
+	})
+	if err != nil {
+		return err
+	}
 
-		if !reflect.DeepEqual(existingRB.Subjects, roleBinding.Subjects) ||
-			!reflect.DeepEqual(existingRB.RoleRef, roleBinding.RoleRef) {
+	fmt.Println("RBAC removed for:", username)
 
-			existingRB.Subjects = roleBinding.Subjects
-			existingRB.RoleRef = roleBinding.RoleRef
+	return nil
+}
 
-			_, err = clientset.RbacV1().
-				RoleBindings(namespace).
-				Update(ctx, existingRB, metav1.UpdateOptions{})
-			if err != nil {
-				return err
-			}
+// Ready reports whether the developer's ServiceAccount and RoleBinding exist.
+func Ready(clientset *kubernetes.Clientset, namespace, username, identityNamespace string) (bool, error) {
 
-			fmt.Println("RoleBinding updated")
-		} else {
-			fmt.Println("RoleBinding already matches desired state")
-		}
+	ctx := context.Background()
+
+	_, err := clientset.CoreV1().
+		ServiceAccounts(identityNamespace).
+		Get(ctx, username, metav1.GetOptions{})
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	_, err = clientset.RbacV1().
+		RoleBindings(namespace).
+		Get(ctx, BindingName(username), metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
@@ -0,0 +1,24 @@
+package rbac
+
+import "testing"
+
+func TestNames(t *testing.T) {
+
+	tests := []struct {
+		username string
+		role     string
+		binding  string
+	}{
+		{"aman", "aman-role", "aman-binding"},
+		{"aman-x", "aman-x-role", "aman-x-binding"},
+	}
+
+	for _, tt := range tests {
+		if got := RoleName(tt.username); got != tt.role {
+			t.Errorf("RoleName(%q) = %q, want %q", tt.username, got, tt.role)
+		}
+		if got := BindingName(tt.username); got != tt.binding {
+			t.Errorf("BindingName(%q) = %q, want %q", tt.username, got, tt.binding)
+		}
+	}
+}
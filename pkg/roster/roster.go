@@ -0,0 +1,169 @@
+package roster
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Entry is one developer to provision. Team, Profile and TTL are optional.
+type Entry struct {
+	User    string `json:"user"`
+	Team    string `json:"team,omitempty"`
+	Profile string `json:"profile,omitempty"`
+	TTL     string `json:"ttl,omitempty"`
+}
+
+// Profile is a named set of quota limits.
+type Profile struct {
+	CPU     string
+	Memory  string
+	MaxPods int
+}
+
+// Profiles are the quota profiles a roster can refer to.
+var Profiles = map[string]Profile{
+	"small":  {CPU: "1", Memory: "1Gi", MaxPods: 5},
+	"medium": {CPU: "2", Memory: "2Gi", MaxPods: 10},
+	"large":  {CPU: "4", Memory: "4Gi", MaxPods: 20},
+}
+
+// columns are the CSV columns, of which user is required.
+var columns = []string{"user", "team", "profile", "ttl"}
+
+// Load reads a roster from a CSV file with a header row, or from a YAML file
+// holding a list of entries, depending on its extension.
+func Load(path string) ([]Entry, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = readCSV(f)
+	case ".yaml", ".yml":
+		entries, err = readYAML(f)
+	default:
+		return nil, fmt.Errorf("%s: roster must be a .csv, .yaml or .yml file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i, entry := range entries {
+		if entry.User == "" {
+			return nil, fmt.Errorf("%s: entry %d has no user", path, i+1)
+		}
+		if seen[entry.User] {
+			return nil, fmt.Errorf("%s: user %s is listed twice", path, entry.User)
+		}
+		seen[entry.User] = true
+	}
+
+	return entries, nil
+}
+
+func readCSV(r io.Reader) ([]Entry, error) {
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(columns, name) {
+			return nil, fmt.Errorf("unknown column %q (columns: %s)", name, strings.Join(columns, ", "))
+		}
+		index[name] = i
+	}
+	if _, ok := index["user"]; !ok {
+		return nil, fmt.Errorf("missing column user")
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := index[name]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	entries := []Entry{}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, Entry{
+			User:    field(record, "user"),
+			Team:    field(record, "team"),
+			Profile: field(record, "profile"),
+			TTL:     field(record, "ttl"),
+		})
+	}
+
+	return entries, nil
+}
+
+func readYAML(r io.Reader) ([]Entry, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+
+	err = yaml.UnmarshalStrict(data, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ParseTTL parses a time to live such as 72h or 30d.
+func ParseTTL(ttl string) (time.Duration, error) {
+
+	var d time.Duration
+	var err error
+
+	if days, ok := strings.CutSuffix(ttl, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(ttl)
+	}
+
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q: must be a positive duration such as 72h or 30d", ttl)
+	}
+
+	return d, nil
+}
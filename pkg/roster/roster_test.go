@@ -0,0 +1,168 @@
+package roster
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTTL(t *testing.T) {
+
+	tests := []struct {
+		ttl     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"72h", 72 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"30d", 30 * 24 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"0d", 0, true},
+		{"-1d", 0, true},
+		{"0h", 0, true},
+		{"d", 0, true},
+		{"1w", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ttl, func(t *testing.T) {
+			got, err := ParseTTL(tt.ttl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTTL(%q) error = %v, wantErr %v", tt.ttl, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTTL(%q) = %v, want %v", tt.ttl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		csv     string
+		want    []Entry
+		wantErr string
+	}{
+		{
+			name: "all columns",
+			csv:  "user,team,profile,ttl\naman,payments,large,30d\ndana,,,\n",
+			want: []Entry{
+				{User: "aman", Team: "payments", Profile: "large", TTL: "30d"},
+				{User: "dana"},
+			},
+		},
+		{
+			name: "reordered columns with spaces and comments",
+			csv:  "# interns\nTTL, User\n72h, aman\n",
+			want: []Entry{{User: "aman", TTL: "72h"}},
+		},
+		{
+			name: "header only",
+			csv:  "user\n",
+			want: []Entry{},
+		},
+		{
+			name:    "unknown column",
+			csv:     "user,email\naman,aman@example.com\n",
+			wantErr: `unknown column "email"`,
+		},
+		{
+			name:    "missing user column",
+			csv:     "team\npayments\n",
+			wantErr: "missing column user",
+		},
+		{
+			name:    "short record",
+			csv:     "user,team\naman\n",
+			wantErr: "wrong number of fields",
+		},
+		{
+			name:    "empty",
+			csv:     "",
+			wantErr: "reading header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readCSV(strings.NewReader(tt.csv))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readCSV() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readCSV() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readCSV() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+
+	tests := []struct {
+		file    string
+		content string
+		want    []Entry
+		wantErr string
+	}{
+		{
+			file:    "roster.yaml",
+			content: "- user: aman\n  profile: small\n- user: dana\n",
+			want:    []Entry{{User: "aman", Profile: "small"}, {User: "dana"}},
+		},
+		{
+			file:    "roster.csv",
+			content: "user\naman\naman\n",
+			wantErr: "user aman is listed twice",
+		},
+		{
+			file:    "roster.yml",
+			content: "- team: payments\n",
+			wantErr: "entry 1 has no user",
+		},
+		{
+			file:    "roster.yaml",
+			content: "- user: aman\n  email: aman@example.com\n",
+			wantErr: "unknown field",
+		},
+		{
+			file:    "roster.txt",
+			content: "aman\n",
+			wantErr: "must be a .csv, .yaml or .yml file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file+" "+tt.wantErr, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+)
+
+// Environment is the desired state of a developer environment.
+type Environment struct {
+	Owner   string `json:"owner"`
+	Env     string `json:"env,omitempty"`
+	Team    string `json:"team,omitempty"`
+	CPU     string `json:"cpu"`
+	Memory  string `json:"memory"`
+	MaxPods int    `json:"maxPods"`
+	Storage string `json:"storage,omitempty"`
+	// PodSecurity is the Pod Security Standard enforced in the namespace,
+	// namespacepkg.PodSecurityNone to leave it to the cluster default, or
+	// empty to keep the label the namespace already has.
+	PodSecurity string `json:"podSecurity,omitempty"`
+	// ExpiresAt is when the environment may be cleaned up, in RFC 3339, or
+	// empty if it does not expire.
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	// Burst temporarily raises the quota, or is nil.
+	Burst *Burst `json:"burst,omitempty"`
+}
+
+// Burst raises the quota of an environment until ExpiresAt. Empty or zero
+// limits, and limits below the environment's own, keep the environment's.
+type Burst struct {
+	CPU     string `json:"cpu,omitempty"`
+	Memory  string `json:"memory,omitempty"`
+	Storage string `json:"storage,omitempty"`
+	MaxPods int    `json:"maxPods,omitempty"`
+	// ExpiresAt is when the quota is restored, in RFC 3339.
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// Active reports whether the burst is set and has not expired at now. A
+// burst with an unreadable expiry has expired.
+func (b *Burst) Active(now time.Time) bool {
+
+	if b == nil {
+		return false
+	}
+
+	expires, err := time.Parse(time.RFC3339, b.ExpiresAt)
+	if err != nil {
+		return false
+	}
+
+	return now.Before(expires)
+}
+
+// Effective returns the environment with the limits that are enforced at
+// now: while its burst is active, the higher of each limit and the burst's.
+func (e Environment) Effective(now time.Time) Environment {
+
+	if !e.Burst.Active(now) {
+		return e
+	}
+
+	e.CPU = higher(e.CPU, e.Burst.CPU)
+	e.Memory = higher(e.Memory, e.Burst.Memory)
+	e.Storage = higher(e.Storage, e.Burst.Storage)
+	e.MaxPods = max(e.MaxPods, e.Burst.MaxPods)
+
+	return e
+}
+
+// higher returns the higher of two quantities. An empty burst keeps limit,
+// and a limit that does not parse is replaced by the burst.
+func higher(limit, burst string) string {
+
+	if burst == "" {
+		return limit
+	}
+
+	l, err := resource.ParseQuantity(limit)
+	if err != nil {
+		return burst
+	}
+	b, err := resource.ParseQuantity(burst)
+	if err != nil {
+		return limit
+	}
+
+	if b.Cmp(l) > 0 {
+		return burst
+	}
+	return limit
+}
+
+// Namespace returns the namespace of the environment.
+func (e Environment) Namespace() string {
+	return namespacepkg.Name(e.Owner, e.Env)
+}
+
+// HomeNamespace returns the namespace of the owner's default environment,
+// which holds the owner's ServiceAccount.
+func (e Environment) HomeNamespace() string {
+	return namespacepkg.Name(e.Owner, "")
+}
+
+// Record returns the environment as recorded in namespacepkg.SpecAnnotation.
+func (e Environment) Record() (string, error) {
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// Recorded returns the environment recorded on ns, and false if ns has no
+// recorded settings.
+func Recorded(ns *corev1.Namespace) (Environment, bool, error) {
+
+	value, ok := ns.Annotations[namespacepkg.SpecAnnotation]
+	if !ok {
+		return Environment{}, false, nil
+	}
+
+	var e Environment
+
+	err := json.Unmarshal([]byte(value), &e)
+	if err != nil {
+		return Environment{}, false, fmt.Errorf("namespace %s: invalid %s annotation: %w", ns.Name, namespacepkg.SpecAnnotation, err)
+	}
+
+	return e, true, nil
+}
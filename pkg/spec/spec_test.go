@@ -0,0 +1,163 @@
+package spec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+)
+
+func TestHigher(t *testing.T) {
+
+	tests := []struct {
+		limit, burst, want string
+	}{
+		{"2", "4", "4"},
+		{"4", "2", "4"},
+		{"2Gi", "2048Mi", "2Gi"},
+		{"2Gi", "3000Mi", "3000Mi"},
+		{"500m", "1", "1"},
+		{"2", "", "2"},
+		{"2Gi", "8GB", "2Gi"},
+		{"", "4", "4"},
+	}
+
+	for _, tt := range tests {
+		if got := higher(tt.limit, tt.burst); got != tt.want {
+			t.Errorf("higher(%q, %q) = %q, want %q", tt.limit, tt.burst, got, tt.want)
+		}
+	}
+}
+
+func TestBurstActive(t *testing.T) {
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		burst *Burst
+		want  bool
+	}{
+		{"none", nil, false},
+		{"active", &Burst{ExpiresAt: "2026-10-19T13:00:00Z"}, true},
+		{"expired", &Burst{ExpiresAt: "2026-10-19T11:00:00Z"}, false},
+		{"expires now", &Burst{ExpiresAt: "2026-10-19T12:00:00Z"}, false},
+		{"unreadable expiry", &Burst{ExpiresAt: "tomorrow"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.burst.Active(now); got != tt.want {
+				t.Errorf("Active() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEffective(t *testing.T) {
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	active := "2026-10-20T12:00:00Z"
+	expired := "2026-10-18T12:00:00Z"
+
+	baseline := Environment{Owner: "aman", CPU: "2", Memory: "2Gi", Storage: "5Gi", MaxPods: 10}
+
+	tests := []struct {
+		name  string
+		burst *Burst
+		want  [4]any
+	}{
+		{"no burst", nil, [4]any{"2", "2Gi", "5Gi", 10}},
+		{"raises given limits", &Burst{Memory: "8Gi", MaxPods: 20, ExpiresAt: active}, [4]any{"2", "8Gi", "5Gi", 20}},
+		{"keeps higher baseline", &Burst{CPU: "1", ExpiresAt: active}, [4]any{"2", "2Gi", "5Gi", 10}},
+		{"ignores unparsable burst", &Burst{Memory: "8GB", ExpiresAt: active}, [4]any{"2", "2Gi", "5Gi", 10}},
+		{"expired", &Burst{CPU: "8", Storage: "50Gi", ExpiresAt: expired}, [4]any{"2", "2Gi", "5Gi", 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := baseline
+			env.Burst = tt.burst
+
+			got := env.Effective(now)
+			if limits := [4]any{got.CPU, got.Memory, got.Storage, got.MaxPods}; limits != tt.want {
+				t.Errorf("Effective() limits = %v, want %v", limits, tt.want)
+			}
+			if got.Burst != tt.burst {
+				t.Errorf("Effective() changed the burst")
+			}
+		})
+	}
+}
+
+func TestRecorded(t *testing.T) {
+
+	env := Environment{
+		Owner:       "aman",
+		Env:         "feature-x",
+		Team:        "payments",
+		CPU:         "2",
+		Memory:      "2Gi",
+		MaxPods:     10,
+		Storage:     "5Gi",
+		PodSecurity: "baseline",
+		Burst:       &Burst{CPU: "4", ExpiresAt: "2026-10-20T12:00:00Z"},
+	}
+
+	record, err := env.Record()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        Environment
+		wantOK      bool
+		wantErr     bool
+	}{
+		{"recorded", map[string]string{namespacepkg.SpecAnnotation: record}, env, true, false},
+		{"not recorded", nil, Environment{}, false, false},
+		{"invalid", map[string]string{namespacepkg.SpecAnnotation: "{"}, Environment{}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev-aman-feature-x", Annotations: tt.annotations}}
+
+			got, ok, err := Recorded(ns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Recorded() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Recorded() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNamespace(t *testing.T) {
+
+	tests := []struct {
+		env       Environment
+		namespace string
+		home      string
+	}{
+		{Environment{Owner: "aman"}, "dev-aman", "dev-aman"},
+		{Environment{Owner: "aman", Env: namespacepkg.DefaultEnv}, "dev-aman", "dev-aman"},
+		{Environment{Owner: "aman", Env: "feature-x"}, "dev-aman-feature-x", "dev-aman"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.env.Namespace(); got != tt.namespace {
+			t.Errorf("%+v.Namespace() = %q, want %q", tt.env, got, tt.namespace)
+		}
+		if got := tt.env.HomeNamespace(); got != tt.home {
+			t.Errorf("%+v.HomeNamespace() = %q, want %q", tt.env, got, tt.home)
+		}
+	}
+}
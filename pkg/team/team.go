@@ -0,0 +1,168 @@
+package team
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/sarthakK31/podcraft/pkg/kube"
+	"github.com/sarthakK31/podcraft/pkg/namespacepkg"
+	"github.com/sarthakK31/podcraft/pkg/rbac"
+)
+
+const (
+	MembersAnnotation = "podcraft.dev/members"
+	LeadAnnotation    = "podcraft.dev/lead"
+)
+
+// Team is a shared namespace whose members are bound with their own
+// developer identities.
+type Team struct {
+	Name    string
+	Members []string
+	Lead    string
+}
+
+// Namespace returns the namespace of a team: team-<name>.
+func Namespace(name string) string {
+	return "team-" + name
+}
+
+// Get reads the team membership recorded on the team namespace.
+func Get(clientset *kubernetes.Clientset, name string) (*Team, error) {
+
+	ns, err := clientset.CoreV1().
+		Namespaces().
+		Get(context.Background(), Namespace(name), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return fromNamespace(name, ns), nil
+}
+
+func fromNamespace(name string, ns *corev1.Namespace) *Team {
+
+	t := &Team{
+		Name: name,
+		Lead: ns.Annotations[LeadAnnotation],
+	}
+
+	if members := ns.Annotations[MembersAnnotation]; members != "" {
+		t.Members = strings.Split(members, ",")
+	}
+
+	return t
+}
+
+// EnsureNamespace creates the team namespace if it does not exist.
+func EnsureNamespace(clientset *kubernetes.Clientset, name string, r *kube.Reporter) error {
+
+	namespaceName := Namespace(name)
+
+	namespaces := clientset.CoreV1().Namespaces()
+
+	labels := kube.Labels("", name)
+	labels[namespacepkg.ManagedLabel] = "true"
+
+	ns := corev1ac.Namespace(namespaceName).WithLabels(labels)
+
+	result, err := kube.Apply(
+		r,
+		func(ctx context.Context) (*corev1.Namespace, error) {
+			return namespaces.Get(ctx, namespaceName, metav1.GetOptions{})
+		},
+		func(ctx context.Context, opts metav1.ApplyOptions) (*corev1.Namespace, error) {
+			return namespaces.Apply(ctx, ns, opts)
+		},
+		func(ns *corev1.Namespace) any {
+			return ns.Labels
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	r.Report("Namespace "+namespaceName, result)
+
+	return nil
+}
+
+// Reconcile binds every member and the lead to the team namespace with their
+// developer identity, revokes access of developers no longer on the team and
+// records the membership on the namespace.
+func Reconcile(clientset *kubernetes.Clientset, t *Team, r *kube.Reporter) error {
+
+	ctx := context.Background()
+
+	namespaceName := Namespace(t.Name)
+
+	ns, err := clientset.CoreV1().
+		Namespaces().
+		Get(ctx, namespaceName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	desired := map[string]rbac.Level{}
+	for _, member := range t.Members {
+		desired[member] = rbac.Developer
+	}
+	if t.Lead != "" {
+		desired[t.Lead] = rbac.Lead
+	}
+
+	// Every member needs a default environment holding their identity
+	for member := range desired {
+		_, err := clientset.CoreV1().
+			Namespaces().
+			Get(ctx, namespacepkg.Name(member, ""), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%s has no developer environment; run \"podcraft create %s\" first", member, member)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	previous := fromNamespace(t.Name, ns)
+	for _, member := range append(previous.Members, previous.Lead) {
+		if _, ok := desired[member]; member != "" && !ok {
+			err := rbac.RemoveRBAC(clientset, namespaceName, member)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	members := make([]string, 0, len(desired))
+	for member, level := range desired {
+		err := rbac.EnsureRBAC(clientset, namespaceName, member, namespacepkg.Name(member, ""), level, kube.Labels(member, t.Name), r)
+		if err != nil {
+			return err
+		}
+		if level == rbac.Developer {
+			members = append(members, member)
+		}
+	}
+	slices.Sort(members)
+
+	err = namespacepkg.Update(clientset, namespaceName, func(ns *corev1.Namespace) {
+		ns.Annotations[MembersAnnotation] = strings.Join(members, ",")
+		ns.Annotations[LeadAnnotation] = t.Lead
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Println("Team members reconciled:", namespaceName)
+
+	return nil
+}
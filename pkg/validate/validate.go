@@ -0,0 +1,83 @@
+package validate
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// MaxNameLength leaves room for the longest suffix PodCraft appends to a
+// username ("-binding") within the 63 characters of a DNS-1123 label.
+const MaxNameLength = validation.DNS1123LabelMaxLength - len("-binding")
+
+// reserved names would collide with system namespaces or PodCraft's own.
+var reserved = []string{
+	"admin",
+	"default",
+	"kube",
+	"podcraft",
+	"root",
+	"shared",
+	"system",
+}
+
+// Name validates a username, environment or team name.
+func Name(kind, name string) error {
+
+	if name == "" {
+		return fmt.Errorf("%s must not be empty", kind)
+	}
+
+	if len(name) > MaxNameLength {
+		return fmt.Errorf("%s %q is too long (%d characters, max %d)", kind, name, len(name), MaxNameLength)
+	}
+
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return fmt.Errorf("invalid %s %q: %s", kind, name, strings.Join(errs, "; "))
+	}
+
+	if slices.Contains(reserved, name) || strings.HasPrefix(name, "kube-") || strings.HasPrefix(name, "system-") {
+		return fmt.Errorf("%s %q is reserved", kind, name)
+	}
+
+	return nil
+}
+
+// Namespace validates a namespace name derived from user input, e.g.
+// dev-<user>-<env>, which may exceed the limit even if its parts do not.
+func Namespace(name string) error {
+
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace %q: %s", name, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Quantity validates a positive resource quantity given for flag.
+func Quantity(flag, value string) error {
+
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return fmt.Errorf("invalid --%s %q: must be a Kubernetes quantity such as 500m, 2 or 4Gi", flag, value)
+	}
+
+	if q.Sign() <= 0 {
+		return fmt.Errorf("invalid --%s %q: must be greater than zero", flag, value)
+	}
+
+	return nil
+}
+
+// Count validates a positive count given for flag.
+func Count(flag string, value int) error {
+
+	if value <= 0 {
+		return fmt.Errorf("invalid --%s %d: must be greater than zero", flag, value)
+	}
+
+	return nil
+}
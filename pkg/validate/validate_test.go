@@ -0,0 +1,105 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{"aman", ""},
+		{"aman-x", ""},
+		{"a1", ""},
+		{"", "must not be empty"},
+		{strings.Repeat("a", MaxNameLength), ""},
+		{strings.Repeat("a", MaxNameLength+1), "too long"},
+		{"Aman", "invalid"},
+		{"aman_x", "invalid"},
+		{"-aman", "invalid"},
+		{"admin", "reserved"},
+		{"kube-aman", "reserved"},
+		{"system-aman", "reserved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, Name("username", tt.name), tt.wantErr)
+		})
+	}
+}
+
+func TestNamespace(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{"dev-aman", ""},
+		{"dev-aman-feature-x", ""},
+		{"dev-" + strings.Repeat("a", 60), "invalid namespace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkErr(t, Namespace(tt.name), tt.wantErr)
+		})
+	}
+}
+
+func TestQuantity(t *testing.T) {
+
+	tests := []struct {
+		value   string
+		wantErr string
+	}{
+		{"2", ""},
+		{"500m", ""},
+		{"4Gi", ""},
+		{"8GB", "must be a Kubernetes quantity"},
+		{"", "must be a Kubernetes quantity"},
+		{"0", "greater than zero"},
+		{"-1", "greater than zero"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			checkErr(t, Quantity("memory", tt.value), tt.wantErr)
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+
+	tests := []struct {
+		value   int
+		wantErr string
+	}{
+		{1, ""},
+		{10, ""},
+		{0, "greater than zero"},
+		{-5, "greater than zero"},
+	}
+
+	for _, tt := range tests {
+		checkErr(t, Count("max-pods", tt.value), tt.wantErr)
+	}
+}
+
+// checkErr fails t unless err is nil when wantErr is empty, or contains
+// wantErr otherwise.
+func checkErr(t *testing.T, err error, wantErr string) {
+	t.Helper()
+
+	switch {
+	case wantErr == "" && err != nil:
+		t.Errorf("unexpected error: %v", err)
+	case wantErr != "" && err == nil:
+		t.Errorf("expected an error containing %q", wantErr)
+	case wantErr != "" && !strings.Contains(err.Error(), wantErr):
+		t.Errorf("error %q does not contain %q", err, wantErr)
+	}
+}